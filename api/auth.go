@@ -0,0 +1,403 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+type permissionInput struct {
+	Namespace string `json:"namespace,omitempty"`
+	Prefix    string `json:"prefix"`
+	Read      bool   `json:"read"`
+	Write     bool   `json:"write"`
+}
+
+type roleOutput struct {
+	Name        string            `json:"name"`
+	Permissions []permissionInput `json:"permissions"`
+}
+
+func newRoleOutput(r store.Role) roleOutput {
+	out := roleOutput{Name: r.Name, Permissions: make([]permissionInput, 0, len(r.Permissions))}
+	for _, perm := range r.Permissions {
+		out.Permissions = append(out.Permissions, permissionInput{
+			Namespace: perm.Namespace,
+			Prefix:    perm.Prefix,
+			Read:      perm.Read,
+			Write:     perm.Write,
+		})
+	}
+
+	return out
+}
+
+type tokenOutput struct {
+	ID        string `json:"id"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	// Token is only returned once, when the token is created.
+	Token string `json:"token,omitempty"`
+}
+
+func newTokenOutput(t store.Token) tokenOutput {
+	out := tokenOutput{ID: t.ID}
+	if t.ExpiresAt != 0 {
+		out.ExpiresAt = time.Unix(0, t.ExpiresAt).UTC().Format(time.RFC3339Nano)
+	}
+
+	return out
+}
+
+type userOutput struct {
+	Name   string        `json:"name"`
+	Roles  []string      `json:"roles"`
+	Tokens []tokenOutput `json:"tokens,omitempty"`
+}
+
+// authError answers the errors of the auth actions.
+func authError(l *slog.Logger, r *http.Request, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.UserNotFoundError):
+		notFound(l, w, codeUserNotFound, err)
+	case errors.Is(err, store.RoleNotFoundError):
+		notFound(l, w, codeRoleNotFound, err)
+	case errors.Is(err, store.TokenNotFoundError):
+		notFound(l, w, codeTokenNotFound, err)
+	case errors.Is(err, store.NoRootUserError), errors.Is(err, store.LastRootTokenError):
+		conflict(l, w, codeRootRequired, err)
+	case errors.Is(err, store.RootRoleFixedError), errors.Is(err, store.InvalidAuthNameError):
+		badRequest(l, w, err)
+	default:
+		serverError(l, r, w, err)
+	}
+}
+
+func proposeAuth(r *http.Request, p internalRaft.Proposer, action internalRaft.StoreAction) error {
+	ctx, cancel := proposalContext(r)
+	defer cancel()
+
+	_, err := p.Propose(ctx, action)
+	return err
+}
+
+// @title Auth status
+// @description reports whether requests must carry a token
+// @success 200
+// @router /auth/status [get]
+func NewAuthStatusHandler(l *slog.Logger, s *store.Store) http.Handler {
+	type output struct {
+		Enabled bool `json:"enabled"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(l, output{Enabled: s.AuthEnabled()}, w, http.StatusOK)
+	})
+}
+
+// @title Enable auth
+// @description requires a token on every request from now on. A user with the root role must hold a token beforehand
+// @success 204
+// @failure 409
+// @router /auth/enable [post]
+func NewAuthEnableHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := proposeAuth(r, p, internalRaft.StoreAction{Action: internalRaft.AuthEnable}); err != nil {
+			authError(l, r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// @title Disable auth
+// @description lets every request through without a token
+// @success 204
+// @router /auth/disable [post]
+func NewAuthDisableHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := proposeAuth(r, p, internalRaft.StoreAction{Action: internalRaft.AuthDisable}); err != nil {
+			authError(l, r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// @title List users
+// @description lists the users and their roles
+// @success 200
+// @router /auth/users [get]
+func NewUsersListHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := make([]userOutput, 0)
+		for _, u := range s.Users() {
+			out = append(out, userOutput{Name: u.Name, Roles: u.Roles})
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
+
+// @title Get user
+// @description returns a user's roles and tokens, without their secrets
+// @param name path string true "user name"
+// @success 200
+// @failure 404
+// @router /auth/users/{name} [get]
+func NewUserGetHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := s.GetUser(r.PathValue("name"))
+		if err != nil {
+			notFound(l, w, codeUserNotFound, err)
+			return
+		}
+
+		out := userOutput{Name: u.Name, Roles: u.Roles}
+		for _, t := range s.Tokens(u.Name) {
+			out.Tokens = append(out.Tokens, newTokenOutput(t))
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
+
+// @title Put user
+// @description creates a user or replaces its roles. The root role grants everything, including administration
+// @accept json
+// @param name path string true "user name"
+// @param input body api.NewUserPutHandler.input true "Roles"
+// @success 200
+// @failure 404
+// @router /auth/users/{name} [put]
+func NewUserPutHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	type input struct {
+		Roles []string `json:"roles" validate:"dive,required"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		u := store.User{Name: r.PathValue("name"), Roles: in.Roles}
+		if err := proposeAuth(r, p, internalRaft.StoreAction{Action: internalRaft.UserPut, User: &u}); err != nil {
+			authError(l, r, w, err)
+			return
+		}
+
+		if u.Roles == nil {
+			u.Roles = make([]string, 0)
+		}
+		writeJSON(l, userOutput{Name: u.Name, Roles: u.Roles}, w, http.StatusOK)
+	})
+}
+
+// @title Delete user
+// @description deletes a user along with its tokens
+// @param name path string true "user name"
+// @success 204
+// @failure 404
+// @router /auth/users/{name} [delete]
+func NewUserDeleteHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := internalRaft.StoreAction{Action: internalRaft.UserDelete, Key: r.PathValue("name")}
+		if err := proposeAuth(r, p, action); err != nil {
+			authError(l, r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// @title Create token
+// @description issues a token for a user. The token is only returned in this response, requests carry it as a bearer token
+// @accept json
+// @param name path string true "user name"
+// @param input body api.NewTokenCreateHandler.input false "Expiry"
+// @success 201
+// @failure 404
+// @router /auth/users/{name}/tokens [post]
+func NewTokenCreateHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	type input struct {
+		TTLSeconds int64 `json:"ttl_seconds" validate:"gte=0"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil && !errors.Is(err, io.EOF) {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		id, secret, err := newTokenSecret()
+		if err != nil {
+			serverError(l, r, w, err)
+			return
+		}
+
+		t := store.Token{
+			ID:   id,
+			User: r.PathValue("name"),
+			Hash: store.HashTokenSecret(secret),
+		}
+		if in.TTLSeconds > 0 {
+			t.ExpiresAt = time.Now().Add(time.Duration(in.TTLSeconds) * time.Second).UnixNano()
+		}
+
+		if err := proposeAuth(r, p, internalRaft.StoreAction{Action: internalRaft.TokenAdd, Token: &t}); err != nil {
+			authError(l, r, w, err)
+			return
+		}
+
+		out := newTokenOutput(t)
+		out.Token = id + "." + secret
+		writeJSON(l, out, w, http.StatusCreated)
+	})
+}
+
+// newTokenSecret returns a random token ID and secret.
+func newTokenSecret() (string, string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(id), base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// @title Revoke token
+// @description revokes a token, requests carrying it are rejected from now on
+// @param id path string true "token ID"
+// @success 204
+// @failure 404
+// @router /auth/tokens/{id} [delete]
+func NewTokenRevokeHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := internalRaft.StoreAction{Action: internalRaft.TokenRevoke, Key: r.PathValue("id")}
+		if err := proposeAuth(r, p, action); err != nil {
+			authError(l, r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// @title List roles
+// @description lists the roles and the prefixes they grant
+// @success 200
+// @router /auth/roles [get]
+func NewRolesListHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := make([]roleOutput, 0)
+		for _, role := range s.Roles() {
+			out = append(out, newRoleOutput(role))
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
+
+// @title Get role
+// @description returns the prefixes a role grants
+// @param name path string true "role name"
+// @success 200
+// @failure 404
+// @router /auth/roles/{name} [get]
+func NewRoleGetHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, err := s.GetRole(r.PathValue("name"))
+		if err != nil {
+			notFound(l, w, codeRoleNotFound, err)
+			return
+		}
+
+		writeJSON(l, newRoleOutput(role), w, http.StatusOK)
+	})
+}
+
+// @title Put role
+// @description creates a role or replaces its permissions, each granting read, write or both on the keys under a prefix of a namespace, the root key space when omitted. An empty prefix covers every key of the namespace
+// @accept json
+// @param name path string true "role name"
+// @param input body api.NewRolePutHandler.input true "Permissions"
+// @success 200
+// @router /auth/roles/{name} [put]
+func NewRolePutHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	type input struct {
+		Permissions []permissionInput `json:"permissions" validate:"required"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		role := store.Role{Name: r.PathValue("name")}
+		for _, perm := range in.Permissions {
+			role.Permissions = append(role.Permissions, store.Permission{
+				Namespace: perm.Namespace,
+				Prefix:    perm.Prefix,
+				Read:      perm.Read,
+				Write:     perm.Write,
+			})
+		}
+
+		if err := proposeAuth(r, p, internalRaft.StoreAction{Action: internalRaft.RolePut, Role: &role}); err != nil {
+			authError(l, r, w, err)
+			return
+		}
+
+		writeJSON(l, newRoleOutput(role), w, http.StatusOK)
+	})
+}
+
+// @title Delete role
+// @description deletes a role, taking it away from every user
+// @param name path string true "role name"
+// @success 204
+// @failure 404
+// @router /auth/roles/{name} [delete]
+func NewRoleDeleteHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		action := internalRaft.StoreAction{Action: internalRaft.RoleDelete, Key: r.PathValue("name")}
+		if err := proposeAuth(r, p, action); err != nil {
+			authError(l, r, w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+// @title Batch
+// @description applies many puts and deletes atomically as a single raft entry, all of them under the same revision
+// @accept json
+// @param input body api.NewBatchHandler.input true "Operations"
+// @success 200
+// @router /batch [post]
+func NewBatchHandler(l *slog.Logger, p internalRaft.Proposer, conf Config) http.Handler {
+	type operation struct {
+		Op         string  `json:"op"          validate:"oneof=put delete"`
+		Key        *string `json:"key"         validate:"required"`
+		Value      []byte  `json:"value"       validate:"required_if=Op put" swaggertype:"string" format:"base64"`
+		TTLSeconds int64   `json:"ttl_seconds" validate:"gte=0"`
+		Lease      int64   `json:"lease"`
+		Revision   int64   `json:"revision"    validate:"gte=0"`
+	}
+
+	type input struct {
+		Operations []operation `json:"operations" validate:"required,min=1,dive"`
+	}
+
+	type result struct {
+		Op       string `json:"op"`
+		Key      string `json:"key"`
+		Revision int64  `json:"revision"`
+		Found    *bool  `json:"found,omitempty"`
+	}
+
+	type output struct {
+		Revision int64    `json:"revision"`
+		Results  []result `json:"results"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		if len(in.Operations) > conf.MaxBatchOperations {
+			payloadTooLarge(l, w, fmt.Errorf("batch exceeds %d operations", conf.MaxBatchOperations))
+			return
+		}
+
+		now := time.Now()
+		action := internalRaft.StoreAction{
+			Action:  internalRaft.Batch,
+			Actions: make([]internalRaft.StoreAction, 0, len(in.Operations)),
+		}
+		for _, op := range in.Operations {
+			a := internalRaft.StoreAction{
+				Action:   internalRaft.Put,
+				Key:      *op.Key,
+				Value:    op.Value,
+				Lease:    op.Lease,
+				Revision: op.Revision,
+			}
+			if op.Op == "delete" {
+				a.Action = internalRaft.Delete
+			}
+			if op.TTLSeconds > 0 {
+				a.ExpiresAt = now.Add(time.Duration(op.TTLSeconds) * time.Second).UnixNano()
+			}
+
+			action.Actions = append(action.Actions, a)
+		}
+
+		encoded, err := internalRaft.EncodeAction(l, action)
+		if err != nil {
+			serverError(l, r, w, err)
+			return
+		}
+		if len(encoded) > conf.MaxBatchBytes {
+			payloadTooLarge(l, w, fmt.Errorf("batch entry exceeds %d bytes", conf.MaxBatchBytes))
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		res, err := p.Propose(ctx, action)
+		if err != nil {
+			var batchErr store.BatchError
+
+			switch {
+			case errors.As(err, &batchErr) && errors.Is(err, store.LeaseNotFoundError):
+				unprocessableEntity(l, w, validationResponse{
+					fmt.Sprintf("Operations[%d].Lease", batchErr.Index): {"exists"},
+				})
+			case errors.As(err, &batchErr) && errors.Is(err, store.PreconditionFailedError):
+				preconditionFailed(l, w, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		out := output{
+			Results: make([]result, 0, len(res.Batch)),
+		}
+		for i, br := range res.Batch {
+			out.Revision = br.Revision
+
+			rs := result{
+				Op:       in.Operations[i].Op,
+				Key:      br.Key,
+				Revision: br.Revision,
+			}
+			if rs.Op == "delete" {
+				rs.Found = &br.Found
+			}
+
+			out.Results = append(out.Results, rs)
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/raft/v3"
+)
+
+type memberOutput struct {
+	ID        uint64 `json:"id"`
+	PeerAddr  string `json:"peer_addr"`
+	ClientURL string `json:"client_url"`
+	IsLeader  bool   `json:"is_leader"`
+}
+
+// @title List members
+// @description lists the cluster members and their addresses
+// @success 200
+// @router /members [get]
+func NewMembersListHandler(l *slog.Logger, n raft.Node, members *internalRaft.Members) http.Handler {
+	type output struct {
+		Leader  uint64         `json:"leader"`
+		Members []memberOutput `json:"members"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lead := n.Status().Lead
+
+		out := output{
+			Leader:  lead,
+			Members: make([]memberOutput, 0),
+		}
+		for _, member := range members.List() {
+			out.Members = append(out.Members, memberOutput{
+				ID:        member.ID,
+				PeerAddr:  member.PeerAddr,
+				ClientURL: member.ClientURL,
+				IsLeader:  member.ID == lead,
+			})
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
+
+// @title Add member
+// @description adds a member to the cluster through a replicated configuration change. The new node must be started with JOIN=true
+// @accept json
+// @param input body api.NewMemberAddHandler.input true "Member"
+// @success 201
+// @router /members [post]
+func NewMemberAddHandler(l *slog.Logger, c internalRaft.Cluster) http.Handler {
+	type input struct {
+		ID        uint64 `json:"id"         validate:"gt=0"`
+		PeerAddr  string `json:"peer_addr"  validate:"required,hostname_port"`
+		ClientURL string `json:"client_url" validate:"required,url"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		member := internalRaft.Member{
+			ID:        in.ID,
+			PeerAddr:  in.PeerAddr,
+			ClientURL: in.ClientURL,
+		}
+		if err := c.AddMember(ctx, member); err != nil {
+			switch {
+			case errors.Is(err, internalRaft.MemberExistsError):
+				conflict(l, w, codeMemberExists, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		writeJSON(l, memberOutput{
+			ID:        member.ID,
+			PeerAddr:  member.PeerAddr,
+			ClientURL: member.ClientURL,
+		}, w, http.StatusCreated)
+	})
+}
+
+// @title Remove member
+// @description removes a member from the cluster through a replicated configuration change
+// @param id path int true "member ID"
+// @success 204
+// @router /members/{id} [delete]
+func NewMemberRemoveHandler(l *slog.Logger, c internalRaft.Cluster) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		if err := c.RemoveMember(ctx, ID); err != nil {
+			switch {
+			case errors.Is(err, internalRaft.MemberNotFoundError):
+				notFound(l, w, codeMemberNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// @title Transfer leadership
+// @description hands the leadership over to another member and waits until it takes over
+// @accept json
+// @param input body api.NewLeaderTransferHandler.input true "Transferee"
+// @success 200
+// @router /leader/transfer [post]
+func NewLeaderTransferHandler(l *slog.Logger, c internalRaft.Cluster) http.Handler {
+	type input struct {
+		ID uint64 `json:"id" validate:"gt=0"`
+	}
+
+	type output struct {
+		Leader uint64 `json:"leader"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		if err := c.TransferLeadership(ctx, in.ID); err != nil {
+			switch {
+			case errors.Is(err, internalRaft.MemberNotFoundError):
+				notFound(l, w, codeMemberNotFound, err)
+			case errors.Is(err, context.DeadlineExceeded):
+				serviceUnavailable(l, w, codeTransferIncomplete, errors.New("leadership transfer did not complete"))
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		writeJSON(l, output{Leader: in.ID}, w, http.StatusOK)
+	})
+}
+
+// @title Snapshot
+// @description streams a point-in-time snapshot of the store, in the format nodes exchange when catching up
+// @produce octet-stream
+// @success 200
+// @router /snapshot [get]
+func NewSnapshotHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := s.Snapshot()
+		if err != nil {
+			serverError(l, r, w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	})
+}
+
+type clusterMemberOutput struct {
+	ID        uint64 `json:"id"`
+	PeerAddr  string `json:"peer_addr"`
+	ClientURL string `json:"client_url"`
+	Role      string `json:"role"`
+	// Reachable is unknown for members this node never exchanged messages
+	// with, such as the other followers of a follower.
+	Reachable *bool `json:"reachable,omitempty"`
+	// Match, Next, Lag and State are the leader's replication progress, only
+	// known when this node leads.
+	Match *uint64 `json:"match,omitempty"`
+	Next  *uint64 `json:"next,omitempty"`
+	Lag   *uint64 `json:"lag,omitempty"`
+	State string  `json:"state,omitempty"`
+}
+
+type clusterStoreOutput struct {
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+}
+
+// roleOf names the raft role of the member with ID as seen by this node,
+// which only knows its own state and who leads.
+func roleOf(status raft.Status, ID uint64) string {
+	switch {
+	case ID == status.ID && status.RaftState == raft.StateCandidate:
+		return "candidate"
+	case ID == status.ID && status.RaftState == raft.StatePreCandidate:
+		return "pre_candidate"
+	case ID == status.Lead:
+		return "leader"
+	}
+
+	if _, ok := status.Config.Learners[ID]; ok {
+		return "learner"
+	}
+
+	return "follower"
+}
+
+// @title Cluster status
+// @description reports this node's view of the cluster: every member with its role, reachability and, when this node leads, its replication progress, along with the node's indexes, store size, uptime and version
+// @success 200
+// @router /cluster [get]
+func NewClusterHandler(
+	l *slog.Logger,
+	n raft.Node,
+	c internalRaft.Cluster,
+	s *store.Store,
+	members *internalRaft.Members,
+	conf Config,
+) http.Handler {
+	type output struct {
+		ID              uint64                `json:"id"`
+		Role            string                `json:"role"`
+		Term            uint64                `json:"term"`
+		Leader          uint64                `json:"leader"`
+		LeaderClientURL string                `json:"leader_client_url,omitempty"`
+		AppliedIndex    uint64                `json:"applied_index"`
+		CommittedIndex  uint64                `json:"committed_index"`
+		SnapshotIndex   uint64                `json:"snapshot_index"`
+		Store           clusterStoreOutput    `json:"store"`
+		UptimeSeconds   int64                 `json:"uptime_seconds"`
+		Version         string                `json:"version"`
+		Members         []clusterMemberOutput `json:"members"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := n.Status()
+		usage := s.TotalUsage()
+
+		out := output{
+			ID:             status.ID,
+			Role:           roleOf(status, status.ID),
+			Term:           status.Term,
+			Leader:         status.Lead,
+			AppliedIndex:   status.Applied,
+			CommittedIndex: status.Commit,
+			SnapshotIndex:  c.SnapshotIndex(),
+			Store:          clusterStoreOutput{Keys: usage.Keys, Bytes: usage.Bytes},
+			UptimeSeconds:  int64(time.Since(conf.StartedAt).Seconds()),
+			Version:        conf.Version,
+			Members:        make([]clusterMemberOutput, 0),
+		}
+		if leader, ok := members.Get(status.Lead); ok {
+			out.LeaderClientURL = leader.ClientURL
+		}
+
+		// The leader's own match is its last index, which the followers'
+		// lag is measured against.
+		last := status.Progress[status.ID].Match
+		for _, member := range members.List() {
+			m := clusterMemberOutput{
+				ID:        member.ID,
+				PeerAddr:  member.PeerAddr,
+				ClientURL: member.ClientURL,
+				Role:      roleOf(status, member.ID),
+			}
+			if reachable, known := c.Reachable(member.ID); known {
+				m.Reachable = &reachable
+			}
+			if pr, ok := status.Progress[member.ID]; ok {
+				lag := last - min(pr.Match, last)
+				m.Match, m.Next, m.Lag = &pr.Match, &pr.Next, &lag
+				m.State = strings.ToLower(strings.TrimPrefix(pr.State.String(), "State"))
+			}
+
+			out.Members = append(out.Members, m)
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
@@ -0,0 +1,411 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pablovarg/distributed-key-value-store/concurrency"
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+type participantOutput struct {
+	Key      string `json:"key"`
+	Revision int64  `json:"revision"`
+	Lease    int64  `json:"lease"`
+	Value    []byte `json:"value,omitempty"`
+}
+
+func newParticipantOutput(e store.Entry) participantOutput {
+	return participantOutput{
+		Key:      e.Key,
+		Revision: e.CreateRevision,
+		Lease:    e.Lease,
+		Value:    e.Value,
+	}
+}
+
+type releaseInput struct {
+	Key      string `json:"key"      validate:"required"`
+	Revision int64  `json:"revision" validate:"required"`
+}
+
+// acquire blocks until lease holds one of permits slots under prefix, for at
+// most timeout seconds when timeout is set.
+func acquire(
+	l *slog.Logger,
+	r *http.Request,
+	w http.ResponseWriter,
+	p internalRaft.Proposer,
+	s *store.Store,
+	prefix string,
+	lease int64,
+	value []byte,
+	permits int,
+	timeout int64,
+) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		serverError(l, r, w, err)
+		return
+	}
+
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	e, err := concurrency.Acquire(ctx, s, p, prefix, lease, value, permits)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.LeaseNotFoundError):
+			unprocessableEntity(l, w, validationResponse{"Lease": {"exists"}})
+		case errors.Is(err, concurrency.SessionExpiredError):
+			gone(l, w, codeSessionExpired, err)
+		case errors.Is(err, context.DeadlineExceeded):
+			conflict(l, w, codeNotAcquired, fmt.Errorf("not acquired within %d seconds", timeout))
+		case errors.Is(err, context.Canceled):
+			l.Debug("client gave up waiting", "prefix", prefix, "lease", lease)
+		default:
+			serverError(l, r, w, err)
+		}
+		return
+	}
+
+	writeJSON(l, newParticipantOutput(e), w, http.StatusOK)
+}
+
+// release gives up the participant key identified by in, which must live
+// under prefix.
+func release(
+	l *slog.Logger,
+	r *http.Request,
+	w http.ResponseWriter,
+	p internalRaft.Proposer,
+	s *store.Store,
+	prefix string,
+	in releaseInput,
+) {
+	if !strings.HasPrefix(in.Key, prefix) {
+		unprocessableEntity(l, w, validationResponse{"Key": {"prefix"}})
+		return
+	}
+
+	ctx, cancel := proposalContext(r)
+	defer cancel()
+
+	if err := concurrency.Release(ctx, s, p, in.Key, in.Revision); err != nil {
+		switch {
+		case errors.Is(err, store.KeyNotFoundError):
+			notFound(l, w, codeKeyNotFound, err)
+		case errors.Is(err, concurrency.NotHolderError),
+			errors.Is(err, store.PreconditionFailedError):
+			preconditionFailed(l, w, err)
+		default:
+			serverError(l, r, w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @title Lock
+// @description acquires a distributed mutex, blocking until it is held by the given lease. The returned revision is a fencing token that grows with every new holder
+// @accept json
+// @param name path string true "lock name"
+// @param input body api.NewLockHandler.input true "Owning lease"
+// @success 200
+// @router /concurrency/locks/{name}/acquire [post]
+func NewLockHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	type input struct {
+		Lease          int64 `json:"lease"           validate:"required"`
+		TimeoutSeconds int64 `json:"timeout_seconds" validate:"gte=0"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		prefix := concurrency.LockPrefix(r.PathValue("name"))
+		acquire(l, r, w, p, s, prefix, in.Lease, nil, 1, in.TimeoutSeconds)
+	})
+}
+
+// @title Unlock
+// @description releases a distributed mutex held under the given fencing token
+// @accept json
+// @param name path string true "lock name"
+// @param input body api.releaseInput true "Held key and revision"
+// @success 204
+// @router /concurrency/locks/{name}/release [post]
+func NewUnlockHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in releaseInput
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		release(l, r, w, p, s, concurrency.LockPrefix(r.PathValue("name")), in)
+	})
+}
+
+// @title Acquire semaphore
+// @description acquires one of the permits of a counting semaphore, blocking until the given lease holds it
+// @accept json
+// @param name path string true "semaphore name"
+// @param input body api.NewSemaphoreAcquireHandler.input true "Owning lease and permits"
+// @success 200
+// @router /concurrency/semaphores/{name}/acquire [post]
+func NewSemaphoreAcquireHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	type input struct {
+		Lease          int64 `json:"lease"           validate:"required"`
+		Permits        int   `json:"permits"         validate:"gt=0"`
+		TimeoutSeconds int64 `json:"timeout_seconds" validate:"gte=0"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		prefix := concurrency.SemaphorePrefix(r.PathValue("name"))
+		acquire(l, r, w, p, s, prefix, in.Lease, nil, in.Permits, in.TimeoutSeconds)
+	})
+}
+
+// @title Release semaphore
+// @description releases a permit of a counting semaphore
+// @accept json
+// @param name path string true "semaphore name"
+// @param input body api.releaseInput true "Held key and revision"
+// @success 204
+// @router /concurrency/semaphores/{name}/release [post]
+func NewSemaphoreReleaseHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in releaseInput
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		release(l, r, w, p, s, concurrency.SemaphorePrefix(r.PathValue("name")), in)
+	})
+}
+
+// @title Campaign
+// @description campaigns in an election, blocking until the given lease is the leader
+// @accept json
+// @param name path string true "election name"
+// @param input body api.NewCampaignHandler.input true "Owning lease and leader value"
+// @success 200
+// @router /concurrency/elections/{name}/campaign [post]
+func NewCampaignHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	type input struct {
+		Lease          int64  `json:"lease"           validate:"required"`
+		Value          []byte `json:"value"           swaggertype:"string" format:"base64"`
+		TimeoutSeconds int64  `json:"timeout_seconds" validate:"gte=0"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		prefix := concurrency.ElectionPrefix(r.PathValue("name"))
+		acquire(l, r, w, p, s, prefix, in.Lease, in.Value, 1, in.TimeoutSeconds)
+	})
+}
+
+// @title Proclaim
+// @description updates the value announced by the current election leader
+// @accept json
+// @param name path string true "election name"
+// @param input body api.NewProclaimHandler.input true "Leader key, revision and new value"
+// @success 200
+// @router /concurrency/elections/{name}/proclaim [post]
+func NewProclaimHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	type input struct {
+		Key      string `json:"key"      validate:"required"`
+		Revision int64  `json:"revision" validate:"required"`
+		Value    []byte `json:"value"    swaggertype:"string" format:"base64"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		prefix := concurrency.ElectionPrefix(r.PathValue("name"))
+		if !strings.HasPrefix(in.Key, prefix) {
+			unprocessableEntity(l, w, validationResponse{"Key": {"prefix"}})
+			return
+		}
+
+		leaders := concurrency.Holders(s, prefix, 1)
+		if len(leaders) == 0 || leaders[0].Key != in.Key {
+			preconditionFailed(l, w, concurrency.NotHolderError)
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		e, err := concurrency.Proclaim(ctx, s, p, in.Key, in.Revision, in.Value)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.KeyNotFoundError):
+				notFound(l, w, codeKeyNotFound, err)
+			case errors.Is(err, concurrency.NotHolderError),
+				errors.Is(err, store.PreconditionFailedError):
+				preconditionFailed(l, w, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		writeJSON(l, newParticipantOutput(e), w, http.StatusOK)
+	})
+}
+
+// @title Resign
+// @description gives up leadership, or a pending campaign, in an election
+// @accept json
+// @param name path string true "election name"
+// @param input body api.releaseInput true "Leader key and revision"
+// @success 204
+// @router /concurrency/elections/{name}/resign [post]
+func NewResignHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in releaseInput
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		release(l, r, w, p, s, concurrency.ElectionPrefix(r.PathValue("name")), in)
+	})
+}
+
+// @title Election leader
+// @description retrieves the current leader of an election
+// @param name path string true "election name"
+// @success 200
+// @router /concurrency/elections/{name}/leader [get]
+func NewElectionLeaderHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaders := concurrency.Holders(s, concurrency.ElectionPrefix(r.PathValue("name")), 1)
+		if len(leaders) == 0 {
+			notFound(l, w, codeKeyNotFound, errors.New("election has no leader"))
+			return
+		}
+
+		writeJSON(l, newParticipantOutput(leaders[0]), w, http.StatusOK)
+	})
+}
+
+// @title Observe election
+// @description streams every change of leader of an election as newline delimited JSON
+// @param name path string true "election name"
+// @success 200
+// @router /concurrency/elections/{name}/observe [get]
+func NewElectionObserveHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := concurrency.ElectionPrefix(r.PathValue("name"))
+
+		watcher, err := s.Watch(prefix, true, 0)
+		if err != nil {
+			serverError(l, r, w, err)
+			return
+		}
+		defer func() { watcher.Cancel() }()
+
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			serverError(l, r, w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		var last *participantOutput
+		for {
+			leaders := concurrency.Holders(s, prefix, 1)
+			if len(leaders) > 0 {
+				current := newParticipantOutput(leaders[0])
+				if last == nil || last.Revision != current.Revision || string(last.Value) != string(current.Value) {
+					if err := json.NewEncoder(w).Encode(current); err != nil {
+						return
+					}
+					if err := rc.Flush(); err != nil {
+						return
+					}
+					last = &current
+				}
+			}
+
+			select {
+			case _, ok := <-watcher.Events():
+				if ok {
+					continue
+				}
+
+				watcher, err = s.Watch(prefix, true, 0)
+				if err != nil {
+					l.Error("error re-watching election", "prefix", prefix, "err", err)
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
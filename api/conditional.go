@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+// maxLongPoll bounds the wait of a long-polling GET.
+const maxLongPoll = 5 * time.Minute
+
+// etag identifies the version of e by its mod revision, which changes on
+// every write to the key. It is weak since the JSON and raw representations
+// of the value share it.
+func etag(e store.Entry) string {
+	return fmt.Sprintf(`W/"%d"`, e.ModRevision)
+}
+
+// etagMatches reports whether an If-None-Match header lists tag, comparing
+// weakly.
+func etagMatches(header, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readLongPoll reads the wait and index query parameters of a long-polling
+// GET. A zero wait disables it, and index defaults to the revision of the
+// current version of the key.
+func readLongPoll(r *http.Request, s *store.Store, key string) (time.Duration, int64, error) {
+	q := r.URL.Query()
+	if !q.Has("wait") {
+		if q.Has("index") {
+			return 0, 0, errors.New("index requires wait")
+		}
+		return 0, 0, nil
+	}
+
+	wait, err := time.ParseDuration(q.Get("wait"))
+	if err != nil || wait <= 0 || wait > maxLongPoll {
+		return 0, 0, fmt.Errorf("wait must be a positive duration of at most %s", maxLongPoll)
+	}
+
+	if q.Has("index") {
+		index, err := strconv.ParseInt(q.Get("index"), 10, 64)
+		if err != nil || index < 0 {
+			return 0, 0, errors.New("index must be a non-negative integer")
+		}
+		return wait, index, nil
+	}
+
+	if e, err := s.GetEntry(key); err == nil {
+		return wait, e.ModRevision, nil
+	}
+
+	return wait, s.Revision(), nil
+}
+
+// waitForChange blocks until key is written or deleted after index, or ctx
+// is done. It returns straight away when the change already happened, or
+// when the key is missing and the history since index is no longer
+// available to tell whether it was deleted since.
+func waitForChange(ctx context.Context, s *store.Store, key string, index int64) {
+	watcher, changed, err := s.WatchChange(key, index)
+	if changed || err != nil {
+		return
+	}
+	defer watcher.Cancel()
+
+	for {
+		select {
+		case e, ok := <-watcher.Events():
+			if !ok || e.Type != store.ProgressEvent {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
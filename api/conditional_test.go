@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+const pollWait = 200 * time.Millisecond
+
+func mustPut(t *testing.T, s *store.Store, key, value string) store.Entry {
+	t.Helper()
+
+	e, err := s.Put(key, []byte(value), store.PutOptions{})
+	if err != nil {
+		t.Fatalf("put %q: %v", key, err)
+	}
+
+	return e
+}
+
+// pollFor long-polls key after index and returns how long it was held.
+func pollFor(s *store.Store, key string, index int64) time.Duration {
+	ctx, cancel := context.WithTimeout(context.Background(), pollWait)
+	defer cancel()
+
+	start := time.Now()
+	waitForChange(ctx, s, key, index)
+
+	return time.Since(start)
+}
+
+func TestWaitForChangeBlocksOnCurrentVersion(t *testing.T) {
+	s := store.NewKeyValueStore()
+	e := mustPut(t, s, "greeting", "hello")
+
+	// Push the key's write out of the watch history.
+	for i := range 2000 {
+		mustPut(t, s, fmt.Sprintf("other-%d", i), "x")
+	}
+
+	if held := pollFor(s, "greeting", e.ModRevision); held < pollWait {
+		t.Fatalf("poll on an unchanged key returned after %s, want it held for %s", held, pollWait)
+	}
+}
+
+func TestWaitForChangeBlocksAfterRestore(t *testing.T) {
+	s := store.NewKeyValueStore()
+	e := mustPut(t, s, "greeting", "hello")
+
+	data, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if err := s.Restore(data); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	if held := pollFor(s, "greeting", e.ModRevision); held < pollWait {
+		t.Fatalf("poll after a restore returned after %s, want it held for %s", held, pollWait)
+	}
+}
+
+func TestWaitForChangeReturnsOnNewerVersion(t *testing.T) {
+	s := store.NewKeyValueStore()
+	e := mustPut(t, s, "greeting", "hello")
+	mustPut(t, s, "greeting", "hi")
+
+	if held := pollFor(s, "greeting", e.ModRevision); held >= pollWait {
+		t.Fatalf("poll on a key changed since the index was held for %s, want it to return at once", held)
+	}
+}
+
+func TestWaitForChangeWakesOnWrite(t *testing.T) {
+	s := store.NewKeyValueStore()
+	e := mustPut(t, s, "greeting", "hello")
+
+	go func() {
+		time.Sleep(pollWait / 4)
+		if _, err := s.Put("greeting", []byte("hi"), store.PutOptions{}); err != nil {
+			t.Errorf("put: %v", err)
+		}
+	}()
+
+	if held := pollFor(s, "greeting", e.ModRevision); held >= pollWait {
+		t.Fatalf("poll was held for %s, want it to return on the write", held)
+	}
+}
@@ -1,6 +1,12 @@
 package api
 
-import "github.com/go-playground/validator/v10"
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+)
 
 type validationResponse map[string][]string
 
@@ -17,3 +23,22 @@ func buildErrorsResponse(v validator.ValidationErrors) validationResponse {
 
 	return res
 }
+
+// validateInput writes the validation errors for in, if any, and reports
+// whether the handler can carry on.
+func validateInput(l *slog.Logger, r *http.Request, w http.ResponseWriter, in any) bool {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	if err := v.Struct(in); err != nil {
+		var vError validator.ValidationErrors
+
+		switch {
+		case errors.As(err, &vError):
+			unprocessableEntity(l, w, buildErrorsResponse(vError))
+		default:
+			serverError(l, r, w, err)
+		}
+		return false
+	}
+
+	return true
+}
@@ -3,11 +3,12 @@ package api
 import (
 	"context"
 	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/go-playground/validator/v10"
 	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
 	"github.com/pablovarg/distributed-key-value-store/store"
 	"go.etcd.io/raft/v3"
@@ -19,10 +20,12 @@ import (
 // @param input body api.NewPutHandler.input true "Key / Value pair"
 // @success 201
 // @router /values [post]
-func NewPutHandler(l *slog.Logger, n raft.Node) http.Handler {
+func NewPutHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
 	type input struct {
-		Key   *string `json:"key"   validate:"required"`
-		Value []byte  `json:"value" validate:"required" swaggertype:"string" format:"base64"`
+		Key        *string `json:"key"         validate:"required"`
+		Value      []byte  `json:"value"       validate:"required" swaggertype:"string" format:"base64"`
+		TTLSeconds int64   `json:"ttl_seconds" validate:"gte=0"`
+		Lease      int64   `json:"lease"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -32,39 +35,98 @@ func NewPutHandler(l *slog.Logger, n raft.Node) http.Handler {
 			return
 		}
 
-		if !internalRaft.IsLeader(n) {
-			RedirectToLeader(l, w, n)
+		if !validateInput(l, r, w, in) {
 			return
 		}
 
-		v := validator.New(validator.WithRequiredStructEnabled())
-		if err := v.Struct(in); err != nil {
-			var vError validator.ValidationErrors
+		action := internalRaft.StoreAction{
+			Action: internalRaft.Put,
+			Key:    *in.Key,
+			Value:  in.Value,
+			Lease:  in.Lease,
+		}
+		if in.TTLSeconds > 0 {
+			action.ExpiresAt = time.Now().Add(time.Duration(in.TTLSeconds) * time.Second).UnixNano()
+		}
 
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		if _, err := p.Propose(ctx, action); err != nil {
 			switch {
-			case errors.As(err, &vError):
-				unprocessableEntity(l, w, buildErrorsResponse(vError))
+			case errors.Is(err, store.LeaseNotFoundError):
+				unprocessableEntity(l, w, validationResponse{"Lease": {"exists"}})
+			case errors.Is(err, internalRaft.ValueTooLargeError):
+				payloadTooLarge(l, w, err)
 			default:
-				internalError(l, r, w, err)
+				serverError(l, r, w, err)
 			}
 			return
 		}
 
-		a, err := internalRaft.EncodeAction(l, internalRaft.StoreAction{
-			Action: internalRaft.Put,
-			Key:    *in.Key,
-			Value:  in.Value,
-		})
-		if err != nil {
-			internalError(l, r, w, err)
+		w.WriteHeader(http.StatusCreated)
+	})
+}
+
+// @title Raw put
+// @description inserts or updates a key's value with the raw request body, storing its Content-Type along with it
+// @accept */*
+// @param key path string true "key"
+// @param ttl_seconds query int false "seconds until the key expires"
+// @param lease query int false "lease to attach the key to"
+// @success 201
+// @failure 413
+// @router /values/{key} [put]
+func NewRawPutHandler(l *slog.Logger, p internalRaft.Proposer, conf Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var ttl, lease int64
+		var err error
+		if q.Has("ttl_seconds") {
+			if ttl, err = strconv.ParseInt(q.Get("ttl_seconds"), 10, 64); err != nil || ttl < 0 {
+				badRequest(l, w, errors.New("ttl_seconds must be a non-negative integer"))
+				return
+			}
+		}
+		if q.Has("lease") {
+			if lease, err = strconv.ParseInt(q.Get("lease"), 10, 64); err != nil {
+				badRequest(l, w, errors.New("lease must be an integer"))
+				return
+			}
+		}
+
+		value, ok := readRawBody(l, r, w, conf.MaxRequestBytes)
+		if !ok {
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		action := internalRaft.StoreAction{
+			Action:      internalRaft.Put,
+			Key:         r.PathValue("key"),
+			Value:       value,
+			Lease:       lease,
+			ContentType: r.Header.Get("Content-Type"),
+		}
+		if action.ContentType == "" {
+			action.ContentType = octetStream
+		}
+		if ttl > 0 {
+			action.ExpiresAt = time.Now().Add(time.Duration(ttl) * time.Second).UnixNano()
+		}
+
+		ctx, cancel := proposalContext(r)
 		defer cancel()
 
-		if err := n.Propose(ctx, a); err != nil {
-			internalError(l, r, w, err)
+		if _, err := p.Propose(ctx, action); err != nil {
+			switch {
+			case errors.Is(err, store.LeaseNotFoundError):
+				unprocessableEntity(l, w, validationResponse{"Lease": {"exists"}})
+			case errors.Is(err, internalRaft.ValueTooLargeError):
+				payloadTooLarge(l, w, err)
+			default:
+				serverError(l, r, w, err)
+			}
 			return
 		}
 
@@ -73,79 +135,271 @@ func NewPutHandler(l *slog.Logger, n raft.Node) http.Handler {
 }
 
 // @title Get
-// @description retrieves a key's value
+// @description retrieves a key's value. With Accept: application/octet-stream the raw value is returned, under the Content-Type it was written with. The ETag is the key's mod revision: If-None-Match answers 304 while it is current, and wait long-polls until the key changes past index
 // @param query path string true "key"
+// @param wait query string false "how long to wait for a change, as a duration such as 30s"
+// @param index query int false "mod revision to wait past, the current one by default"
+// @param If-None-Match header string false "ETags the client already has"
+// @produce json,octet-stream
 // @success 200
+// @success 304
 // @router /values/{key} [get]
-func NewGetHandler(l *slog.Logger, n raft.Node, s store.Store) http.Handler {
+func NewGetHandler(l *slog.Logger, s *store.Store) http.Handler {
 	type output struct {
-		Key   string `json:"key"`
-		Value []byte `json:"value"`
+		Key         string `json:"key"`
+		Value       []byte `json:"value"`
+		ContentType string `json:"content_type,omitempty"`
+		ModRevision int64  `json:"mod_revision"`
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		key := r.PathValue("key")
 
-		if !internalRaft.IsLeader(n) {
-			RedirectToLeader(l, w, n)
+		wait, index, err := readLongPoll(r, s, key)
+		if err != nil {
+			badRequest(l, w, err)
 			return
 		}
 
-		value, err := s.Get(key)
+		if wait > 0 {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+				serverError(l, r, w, err)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), wait)
+			waitForChange(ctx, s, key, index)
+			cancel()
+
+			if r.Context().Err() != nil {
+				l.Debug("client gave up waiting", "key", key, "index", index)
+				return
+			}
+		}
+
+		e, err := s.GetEntry(key)
 		if err != nil {
 			switch {
 			case errors.Is(err, store.KeyNotFoundError):
-				http.NotFound(w, r)
+				notFound(l, w, codeKeyNotFound, err)
 			default:
-				internalError(l, r, w, err)
+				serverError(l, r, w, err)
 			}
 			return
 		}
 
+		tag := etag(e)
+		w.Header().Set("ETag", tag)
+		w.Header().Set("Vary", "Accept")
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if accepts(r, octetStream) {
+			writeRaw(w, e)
+			return
+		}
+
 		entry := output{
-			Key:   key,
-			Value: value,
+			Key:         key,
+			Value:       e.Value,
+			ContentType: e.ContentType,
+			ModRevision: e.ModRevision,
 		}
 
 		writeJSON(l, entry, w, http.StatusOK)
 	})
 }
 
-// @title Delete
-// @description deletes a key, value pair from the store
-// @param query path string true "key"
+// @title List
+// @description lists the keys starting with prefix, or within [start, end), ordered by key and read at a single revision. Without filters every key is listed
+// @param prefix query string false "prefix of the keys to list"
+// @param start query string false "first key to list"
+// @param end query string false "key right after the last one to list"
+// @param limit query int false "maximum number of keys to return"
 // @success 200
-// @router /values/{key} [delete]
-func NewDeleteHandler(l *slog.Logger, n raft.Node) http.Handler {
+// @router /values [get]
+func NewListHandler(l *slog.Logger, s *store.Store) http.Handler {
+	type value struct {
+		Key            string `json:"key"`
+		Value          []byte `json:"value"`
+		CreateRevision int64  `json:"create_revision"`
+		ModRevision    int64  `json:"mod_revision"`
+		Version        int64  `json:"version"`
+		Lease          int64  `json:"lease,omitempty"`
+	}
+
 	type output struct {
+		Revision int64   `json:"revision"`
+		Values   []value `json:"values"`
+		More     bool    `json:"more"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		if q.Has("prefix") && q.Has("start") {
+			badRequest(l, w, errors.New("prefix and start are mutually exclusive"))
+			return
+		}
+
+		start, end := q.Get("start"), q.Get("end")
+		if q.Has("prefix") {
+			start, end = q.Get("prefix"), store.PrefixEnd(q.Get("prefix"))
+		}
+
+		var limit int
+		if q.Has("limit") {
+			var err error
+			if limit, err = strconv.Atoi(q.Get("limit")); err != nil || limit < 0 {
+				badRequest(l, w, errors.New("limit must be a non-negative integer"))
+				return
+			}
+		}
+
+		entries, revision, more := s.Range(start, end, limit)
+
+		out := output{
+			Revision: revision,
+			Values:   make([]value, 0, len(entries)),
+			More:     more,
+		}
+		for _, e := range entries {
+			out.Values = append(out.Values, value{
+				Key:            e.Key,
+				Value:          e.Value,
+				CreateRevision: e.CreateRevision,
+				ModRevision:    e.ModRevision,
+				Version:        e.Version,
+				Lease:          e.Lease,
+			})
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
+
+// @title Multi get
+// @description retrieves many keys at once, all read at the same revision
+// @accept json
+// @param input body api.NewMultiGetHandler.input true "Keys"
+// @success 200
+// @router /values/_mget [post]
+func NewMultiGetHandler(l *slog.Logger, s *store.Store) http.Handler {
+	type input struct {
+		Keys []string `json:"keys" validate:"required,min=1"`
+	}
+
+	type value struct {
 		Key   string `json:"key"`
 		Value []byte `json:"value"`
 	}
 
+	type output struct {
+		Revision int64    `json:"revision"`
+		Values   []value  `json:"values"`
+		Missing  []string `json:"missing"`
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		key := r.PathValue("key")
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
 
-		if !internalRaft.IsLeader(n) {
-			RedirectToLeader(l, w, n)
+		if !validateInput(l, r, w, in) {
 			return
 		}
 
-		action, err := internalRaft.EncodeAction(l, internalRaft.StoreAction{
+		found, missing, revision := s.GetMany(in.Keys)
+
+		out := output{
+			Revision: revision,
+			Values:   make([]value, 0, len(found)),
+			Missing:  missing,
+		}
+		for _, e := range found {
+			out.Values = append(out.Values, value{Key: e.Key, Value: e.Value})
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
+
+// @title Delete
+// @description deletes a key, value pair from the store
+// @param query path string true "key"
+// @success 200
+// @failure 404
+// @router /values/{key} [delete]
+func NewDeleteHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		_, err := p.Propose(ctx, internalRaft.StoreAction{
 			Action: internalRaft.Delete,
-			Key:    key,
+			Key:    r.PathValue("key"),
 		})
 		if err != nil {
-			internalError(l, r, w, err)
+			switch {
+			case errors.Is(err, store.KeyNotFoundError):
+				notFound(l, w, codeKeyNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+	})
+}
+
+// @title Delete range
+// @description deletes every key starting with prefix, or within [start, end), as a single replicated operation. An empty end leaves the range unbounded
+// @param prefix query string false "prefix of the keys to delete"
+// @param start query string false "first key to delete"
+// @param end query string false "key right after the last one to delete"
+// @success 200
+// @router /values [delete]
+func NewDeleteRangeHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	type output struct {
+		Deleted  int64 `json:"deleted"`
+		Revision int64 `json:"revision"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		if q.Has("prefix") == q.Has("start") {
+			badRequest(l, w, errors.New("exactly one of prefix or start is required"))
+			return
+		}
+
+		start, end := q.Get("start"), q.Get("end")
+		if q.Has("prefix") {
+			start, end = q.Get("prefix"), store.PrefixEnd(q.Get("prefix"))
+		}
+
+		if end != "" && end <= start {
+			badRequest(l, w, errors.New("end must sort after start"))
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := proposalContext(r)
 		defer cancel()
 
-		if err := n.Propose(ctx, action); err != nil {
-			internalError(l, r, w, err)
+		res, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action:   internalRaft.DeleteRange,
+			Key:      start,
+			RangeEnd: end,
+		})
+		if err != nil {
+			serverError(l, r, w, err)
 			return
 		}
+
+		writeJSON(l, output{Deleted: res.Deleted, Revision: res.Revision}, w, http.StatusOK)
 	})
 }
 
@@ -157,10 +411,111 @@ func NewStatusHandler(l *slog.Logger, n raft.Node) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		status, err := n.Status().MarshalJSON()
 		if err != nil {
-			internalError(l, r, w, err)
+			serverError(l, r, w, err)
 			return
 		}
 
 		w.Write(status)
 	})
 }
+
+type counterInput struct {
+	Delta *int64 `json:"delta" validate:"omitempty,gte=0"`
+}
+
+type counterOutput struct {
+	Key      string `json:"key"`
+	Value    int64  `json:"value"`
+	Revision int64  `json:"revision"`
+}
+
+// readCounterInput reads the optional delta of an increment or decrement,
+// which defaults to one.
+func readCounterInput(l *slog.Logger, r *http.Request, w http.ResponseWriter) (int64, bool) {
+	var in counterInput
+	if err := readJSON(l, r, &in); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(l, w, err)
+		return 0, false
+	}
+
+	if !validateInput(l, r, w, in) {
+		return 0, false
+	}
+
+	if in.Delta == nil {
+		return 1, true
+	}
+
+	return *in.Delta, true
+}
+
+func increment(
+	l *slog.Logger,
+	r *http.Request,
+	w http.ResponseWriter,
+	p internalRaft.Proposer,
+	key string,
+	delta int64,
+) {
+	ctx, cancel := proposalContext(r)
+	defer cancel()
+
+	res, err := p.Propose(ctx, internalRaft.StoreAction{
+		Action: internalRaft.Increment,
+		Key:    key,
+		Delta:  delta,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.NotIntegerError):
+			conflict(l, w, codeNotInteger, err)
+		case errors.Is(err, store.OverflowError):
+			conflict(l, w, codeOverflow, err)
+		default:
+			serverError(l, r, w, err)
+		}
+		return
+	}
+
+	writeJSON(l, counterOutput{
+		Key:      key,
+		Value:    res.Counter,
+		Revision: res.Revision,
+	}, w, http.StatusOK)
+}
+
+// @title Increment
+// @description atomically adds delta, one by default, to the 64-bit integer stored in a key. Missing keys start from zero
+// @accept json
+// @param key path string true "key"
+// @param input body api.counterInput false "Delta"
+// @success 200
+// @router /values/{key}/incr [post]
+func NewIncrementHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delta, ok := readCounterInput(l, r, w)
+		if !ok {
+			return
+		}
+
+		increment(l, r, w, p, r.PathValue("key"), delta)
+	})
+}
+
+// @title Decrement
+// @description atomically subtracts delta, one by default, from the 64-bit integer stored in a key. Missing keys start from zero
+// @accept json
+// @param key path string true "key"
+// @param input body api.counterInput false "Delta"
+// @success 200
+// @router /values/{key}/decr [post]
+func NewDecrementHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delta, ok := readCounterInput(l, r, w)
+		if !ok {
+			return
+		}
+
+		increment(l, r, w, p, r.PathValue("key"), -delta)
+	})
+}
@@ -1,24 +1,148 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
 	"go.etcd.io/raft/v3"
 )
 
-func internalError(l *slog.Logger, r *http.Request, w http.ResponseWriter, err error) {
-	l.Error("http internal error", "path", r.URL.String(), "method", r.Method, "error", err.Error())
-	w.WriteHeader(http.StatusInternalServerError)
+// Error codes identify every error the API answers with. They are stable,
+// clients may rely on them rather than on the detail message.
+const (
+	codeBadRequest         = "bad_request"
+	codeValidationFailed   = "validation_failed"
+	codeKeyNotFound        = "key_not_found"
+	codeLeaseNotFound      = "lease_not_found"
+	codeMemberNotFound     = "member_not_found"
+	codeMemberExists       = "member_exists"
+	codeNamespaceNotFound  = "namespace_not_found"
+	codeNamespaceExists    = "namespace_exists"
+	codeAlarmNotFound      = "alarm_not_found"
+	codeNoSpace            = "no_space"
+	codeUserNotFound       = "user_not_found"
+	codeRoleNotFound       = "role_not_found"
+	codeTokenNotFound      = "token_not_found"
+	codeRootRequired       = "root_required"
+	codeUnauthenticated    = "unauthenticated"
+	codePermissionDenied   = "permission_denied"
+	codeNotInteger         = "not_integer"
+	codeOverflow           = "overflow"
+	codeNotAcquired        = "not_acquired"
+	codeSessionExpired     = "session_expired"
+	codeRevisionCompacted  = "revision_compacted"
+	codePreconditionFailed = "precondition_failed"
+	codePayloadTooLarge    = "payload_too_large"
+	codeNotLeader          = "not_leader"
+	codeNoQuorum           = "no_quorum"
+	codeTransferIncomplete = "transfer_incomplete"
+	codeProposalTimeout    = "proposal_timeout"
+	codeRateLimited        = "rate_limited"
+	codeOverloaded         = "overloaded"
+	codeUploadInterrupted  = "upload_interrupted"
+	codeInternal           = "internal"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 9457 problem details object. Code tells programs what
+// went wrong, Detail tells humans.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+	// Retryable reports whether the same request may succeed later, once
+	// the cluster settles.
+	Retryable bool `json:"retryable"`
+	// Errors lists the failed validation tags by field.
+	Errors validationResponse `json:"errors,omitempty"`
+	// Leader is the ID of the leader a follower could not reach, if known.
+	Leader uint64 `json:"leader,omitempty"`
 }
 
+func newProblem(status int, code string, err error) problem {
+	p := problem{
+		Type:   "urn:kv:error:" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+	}
+	if err != nil {
+		p.Detail = err.Error()
+	}
+
+	switch code {
+	case codeNotLeader, codeNoQuorum, codeTransferIncomplete, codeProposalTimeout, codeRateLimited, codeOverloaded, codeUploadInterrupted:
+		p.Retryable = true
+	}
+
+	return p
+}
+
+func writeProblem(l *slog.Logger, w http.ResponseWriter, p problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		l.Error("error writting problem", "code", p.Code, "err", err)
+	}
+}
+
+// proposalTimeout bounds how long a handler waits for its proposal to apply.
+const proposalTimeout = 5 * time.Second
+
+// proposalContext bounds a proposal made for r. It carries the request's
+// trace but not its cancellation, so a client hanging up does not abandon a
+// write midway.
+func proposalContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(r.Context()), proposalTimeout)
+}
+
+// serverError answers errors no handler expects. Proposals that timed out or
+// were dropped for lack of a leader are retryable, anything else is logged
+// as an internal error.
+func serverError(l *slog.Logger, r *http.Request, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		writeProblem(l, w, newProblem(http.StatusGatewayTimeout, codeProposalTimeout, errors.New("proposal was not applied in time")))
+	case errors.Is(err, internalRaft.OverloadedError), errors.Is(err, store.UploadsFullError):
+		overloaded(l, w, err)
+	case errors.Is(err, raft.ErrProposalDropped):
+		writeProblem(l, w, newProblem(http.StatusServiceUnavailable, codeNoQuorum, errors.New("proposal dropped, no leader available")))
+	case errors.Is(err, store.NoSpaceError):
+		writeProblem(l, w, newProblem(http.StatusInsufficientStorage, codeNoSpace, err))
+	case errors.Is(err, store.NamespaceNotFoundError):
+		writeProblem(l, w, newProblem(http.StatusNotFound, codeNamespaceNotFound, err))
+	case errors.Is(err, store.PermissionDeniedError):
+		permissionDenied(l, w, err)
+	case errors.Is(err, store.ChunksMissingError):
+		writeProblem(l, w, newProblem(http.StatusServiceUnavailable, codeUploadInterrupted, errors.New("value chunks were lost to a leader change")))
+	default:
+		l.Error("http internal error", "path", r.URL.String(), "method", r.Method, "error", err.Error())
+		writeProblem(l, w, newProblem(http.StatusInternalServerError, codeInternal, errors.New("internal error")))
+	}
+}
+
+// badRequest answers 413 instead when the body could not be read for
+// exceeding its limit.
 func badRequest(l *slog.Logger, w http.ResponseWriter, err error) {
-	res := map[string]any{
-		"error": err.Error(),
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		payloadTooLarge(l, w, fmt.Errorf("request body exceeds %d bytes", maxBytesErr.Limit))
+		return
 	}
 
-	writeJSON(l, res, w, http.StatusBadRequest)
+	writeProblem(l, w, newProblem(http.StatusBadRequest, codeBadRequest, err))
 }
 
 func unprocessableEntity(
@@ -26,10 +150,65 @@ func unprocessableEntity(
 	w http.ResponseWriter,
 	v validationResponse,
 ) {
-	writeJSON(l, v, w, http.StatusUnprocessableEntity)
+	p := newProblem(http.StatusUnprocessableEntity, codeValidationFailed, errors.New("request failed validation"))
+	p.Errors = v
+	writeProblem(l, w, p)
+}
+
+func notFound(l *slog.Logger, w http.ResponseWriter, code string, err error) {
+	writeProblem(l, w, newProblem(http.StatusNotFound, code, err))
+}
+
+func gone(l *slog.Logger, w http.ResponseWriter, code string, err error) {
+	writeProblem(l, w, newProblem(http.StatusGone, code, err))
+}
+
+func serviceUnavailable(l *slog.Logger, w http.ResponseWriter, code string, err error) {
+	writeProblem(l, w, newProblem(http.StatusServiceUnavailable, code, err))
+}
+
+// notLeader answers requests a follower could not hand to the leader.
+func notLeader(l *slog.Logger, w http.ResponseWriter, lead uint64, err error) {
+	p := newProblem(http.StatusServiceUnavailable, codeNotLeader, err)
+	p.Leader = lead
+	writeProblem(l, w, p)
+}
+
+func conflict(l *slog.Logger, w http.ResponseWriter, code string, err error) {
+	writeProblem(l, w, newProblem(http.StatusConflict, code, err))
+}
+
+func unauthenticated(l *slog.Logger, w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="kv"`)
+	writeProblem(l, w, newProblem(http.StatusUnauthorized, codeUnauthenticated, err))
+}
+
+func permissionDenied(l *slog.Logger, w http.ResponseWriter, err error) {
+	writeProblem(l, w, newProblem(http.StatusForbidden, codePermissionDenied, err))
+}
+
+func preconditionFailed(l *slog.Logger, w http.ResponseWriter, err error) {
+	writeProblem(l, w, newProblem(http.StatusPreconditionFailed, codePreconditionFailed, err))
+}
+
+func payloadTooLarge(l *slog.Logger, w http.ResponseWriter, err error) {
+	writeProblem(l, w, newProblem(http.StatusRequestEntityTooLarge, codePayloadTooLarge, err))
+}
+
+// tooManyRequests answers a client that ran out of its rate limit, telling
+// it how long until a request is let through again.
+func tooManyRequests(l *slog.Logger, w http.ResponseWriter, retryAfter time.Duration, err error) {
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	writeProblem(l, w, newProblem(http.StatusTooManyRequests, codeRateLimited, err))
+}
+
+// overloaded answers a proposal refused by admission control.
+func overloaded(l *slog.Logger, w http.ResponseWriter, err error) {
+	w.Header().Set("Retry-After", "1")
+	writeProblem(l, w, newProblem(http.StatusServiceUnavailable, codeOverloaded, err))
 }
 
-func RedirectToLeader(l *slog.Logger, w http.ResponseWriter, n raft.Node) {
-	w.Header().Set("Location", fmt.Sprintf("%d", n.Status().Lead))
-	w.WriteHeader(http.StatusTemporaryRedirect)
+// retryAfterSeconds rounds d up to the whole seconds of a Retry-After header.
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
 }
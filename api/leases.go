@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+type leaseOutput struct {
+	ID         int64    `json:"id"`
+	TTLSeconds int64    `json:"ttl_seconds"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	Keys       []string `json:"keys,omitempty"`
+}
+
+func newLeaseOutput(lease store.Lease) leaseOutput {
+	return leaseOutput{
+		ID:         lease.ID,
+		TTLSeconds: lease.TTL,
+		ExpiresAt:  time.Unix(0, lease.ExpiresAt).UTC().Format(time.RFC3339Nano),
+		Keys:       lease.KeyList(),
+	}
+}
+
+func readLeaseID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(r.PathValue("id"), 10, 64)
+}
+
+// @title Grant lease
+// @description grants a lease that expires unless kept alive, deleting every key attached to it
+// @accept json
+// @param input body api.NewLeaseGrantHandler.input true "Lease TTL"
+// @success 201
+// @router /leases [post]
+func NewLeaseGrantHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	type input struct {
+		TTLSeconds int64 `json:"ttl_seconds" validate:"gt=0"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		res, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action:    internalRaft.LeaseGrant,
+			TTL:       in.TTLSeconds,
+			ExpiresAt: time.Now().Add(time.Duration(in.TTLSeconds) * time.Second).UnixNano(),
+			Caller:    callerOf(r),
+		})
+		if err != nil {
+			serverError(l, r, w, err)
+			return
+		}
+
+		lease, err := s.GetLease(res.Lease)
+		if err != nil {
+			serverError(l, r, w, err)
+			return
+		}
+
+		writeJSON(l, newLeaseOutput(lease), w, http.StatusCreated)
+	})
+}
+
+// @title Get lease
+// @description retrieves a lease with its deadline and attached keys
+// @param id path int true "lease ID"
+// @success 200
+// @router /leases/{id} [get]
+func NewLeaseGetHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := readLeaseID(r)
+		if err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		lease, err := s.GetLease(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.LeaseNotFoundError):
+				notFound(l, w, codeLeaseNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		writeJSON(l, newLeaseOutput(lease), w, http.StatusOK)
+	})
+}
+
+// @title Keep lease alive
+// @description pushes a lease's deadline back by its TTL
+// @param id path int true "lease ID"
+// @success 200
+// @router /leases/{id}/keepalive [post]
+func NewLeaseKeepAliveHandler(l *slog.Logger, p internalRaft.Proposer, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := readLeaseID(r)
+		if err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		lease, err := s.GetLease(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.LeaseNotFoundError):
+				notFound(l, w, codeLeaseNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		if _, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action:    internalRaft.LeaseKeepAlive,
+			Lease:     id,
+			ExpiresAt: time.Now().Add(time.Duration(lease.TTL) * time.Second).UnixNano(),
+			Caller:    callerOf(r),
+		}); err != nil {
+			switch {
+			case errors.Is(err, store.LeaseNotFoundError):
+				notFound(l, w, codeLeaseNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		lease, err = s.GetLease(id)
+		if err != nil {
+			notFound(l, w, codeLeaseNotFound, err)
+			return
+		}
+
+		writeJSON(l, newLeaseOutput(lease), w, http.StatusOK)
+	})
+}
+
+// @title Revoke lease
+// @description revokes a lease, deleting every key attached to it
+// @param id path int true "lease ID"
+// @success 200
+// @router /leases/{id} [delete]
+func NewLeaseRevokeHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	type output struct {
+		ID      int64 `json:"id"`
+		Deleted int64 `json:"deleted"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := readLeaseID(r)
+		if err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		res, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action: internalRaft.LeaseRevoke,
+			Lease:  id,
+			Caller: callerOf(r),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, store.LeaseNotFoundError):
+				notFound(l, w, codeLeaseNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		writeJSON(l, output{ID: id, Deleted: res.Deleted}, w, http.StatusOK)
+	})
+}
@@ -1,8 +1,22 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/raft/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 func hitLoggingMiddleware(l *slog.Logger) func(http.Handler) http.Handler {
@@ -14,3 +28,170 @@ func hitLoggingMiddleware(l *slog.Logger) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// bodyLimitMiddleware bounds request bodies to limit bytes, answering 413
+// up front when the declared length exceeds it. Reading past the limit fails
+// with an *http.MaxBytesError. Zero leaves bodies unbounded.
+func bodyLimitMiddleware(l *slog.Logger, limit int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit > 0 {
+				if r.ContentLength > int64(limit) {
+					payloadTooLarge(l, w, fmt.Errorf("request body exceeds %d bytes", limit))
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, int64(limit))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedHopsHeader counts how many times a request was forwarded between
+// members, so a stale view of the leader cannot bounce it around forever.
+// peerSecretHeader carries the secret the members share, telling the leader
+// the request was already rate limited.
+const (
+	forwardedHopsHeader = "X-Forwarded-Hops"
+	maxForwardedHops    = 1
+	peerSecretHeader    = "X-Peer-Secret"
+)
+
+// forwardToLeaderMiddleware proxies requests received by a follower to the
+// leader's client URL, as found in the member registry.
+func forwardToLeaderMiddleware(
+	l *slog.Logger,
+	n raft.Node,
+	members *internalRaft.Members,
+	peerSecret string,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if internalRaft.IsLeader(n) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			lead := n.Status().Lead
+			if lead == raft.None {
+				serviceUnavailable(l, w, codeNoQuorum, errors.New("no leader elected"))
+				return
+			}
+
+			hops, _ := strconv.Atoi(r.Header.Get(forwardedHopsHeader))
+			if hops >= maxForwardedHops {
+				notLeader(l, w, lead, errors.New("request was already forwarded, leader is changing"))
+				return
+			}
+
+			leader, ok := members.Get(lead)
+			if !ok || leader.ClientURL == "" {
+				notLeader(l, w, lead, fmt.Errorf("no client URL known for leader %d", lead))
+				return
+			}
+
+			target, err := url.Parse(leader.ClientURL)
+			if err != nil {
+				serverError(l, r, w, err)
+				return
+			}
+
+			// The leader enforces its own timeouts, long polls and streams
+			// must not be cut short here.
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+				serverError(l, r, w, err)
+				return
+			}
+
+			l.Debug("forwarding request to leader", "path", r.URL.String(), "leader", lead, "url", leader.ClientURL)
+			proxy := &httputil.ReverseProxy{
+				Rewrite: func(pr *httputil.ProxyRequest) {
+					pr.SetURL(target)
+					pr.SetXForwarded()
+					pr.Out.Header.Set(forwardedHopsHeader, strconv.Itoa(hops+1))
+					pr.Out.Header.Del(peerSecretHeader)
+					if peerSecret != "" {
+						pr.Out.Header.Set(peerSecretHeader, peerSecret)
+					}
+					otel.GetTextMapPropagator().Inject(pr.Out.Context(), propagation.HeaderCarrier(pr.Out.Header))
+				},
+				FlushInterval: -1,
+				ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+					l.Error("error forwarding request to leader", "leader", lead, "err", err)
+					notLeader(l, w, lead, fmt.Errorf("leader %d unreachable", lead))
+				},
+			}
+			proxy.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authMiddleware returns a middleware per route, checking that the caller's
+// token grants the access the route's accessFunc lists. Every request passes
+// while auth is disabled.
+func authMiddleware(l *slog.Logger, s *store.Store) func(accessFunc) func(http.Handler) http.Handler {
+	return func(accessOf accessFunc) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !s.AuthEnabled() {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				principal, err := authenticate(s, r)
+				if err != nil {
+					unauthenticated(l, w, err)
+					return
+				}
+				r = r.WithContext(context.WithValue(r.Context(), callerKey{}, principal.User))
+
+				if principal.Root {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				accesses, err := accessOf(r)
+				if err != nil {
+					badRequest(l, w, err)
+					return
+				}
+
+				ns := r.PathValue("ns")
+				for _, a := range accesses {
+					if !principal.Permits(ns, a) {
+						l.Info("http permission denied", "path", r.URL.String(), "method", r.Method, "user", principal.User)
+						permissionDenied(l, w, fmt.Errorf("user %q may not %s", principal.User, a))
+						return
+					}
+				}
+
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+}
+
+type callerKey struct{}
+
+// callerOf names the user the request was authenticated as, empty when auth
+// is off.
+func callerOf(r *http.Request) string {
+	caller, _ := r.Context().Value(callerKey{}).(string)
+	return caller
+}
+
+// authenticate finds the principal of the request's bearer token.
+func authenticate(s *store.Store, r *http.Request) (store.Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return store.Principal{}, errors.New("a bearer token is required")
+	}
+
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return store.Principal{}, store.InvalidTokenError
+	}
+
+	return s.Authenticate(id, secret, time.Now().UnixNano())
+}
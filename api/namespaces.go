@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+// keyspaceHandler builds the handler of a route served in every namespace,
+// given the namespace's store and a proposer applying to it.
+type keyspaceHandler func(s *store.Store, p internalRaft.Proposer) http.Handler
+
+// namespaceProposer proposes actions to a namespace's key space.
+type namespaceProposer struct {
+	p         internalRaft.Proposer
+	namespace string
+}
+
+func (np namespaceProposer) Propose(ctx context.Context, action internalRaft.StoreAction) (internalRaft.ActionResult, error) {
+	action.Namespace = np.namespace
+	return np.p.Propose(ctx, action)
+}
+
+// keyspaceOf returns the store of the namespace in the request's path, the
+// root store when there is none.
+func keyspaceOf(s *store.Store, r *http.Request) (*store.Store, error) {
+	ns := r.PathValue("ns")
+	if ns == "" {
+		return s, nil
+	}
+
+	return s.Namespace(ns)
+}
+
+// keyspaceMiddleware serves a keyspaceHandler against the namespace named in
+// the path, or the root key space on routes without one.
+func keyspaceMiddleware(l *slog.Logger, s *store.Store, p internalRaft.Proposer) func(keyspaceHandler) http.Handler {
+	return func(h keyspaceHandler) http.Handler {
+		root := h(s, p)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ns := r.PathValue("ns")
+			if ns == "" {
+				root.ServeHTTP(w, r)
+				return
+			}
+
+			kv, err := s.Namespace(ns)
+			if err != nil {
+				notFound(l, w, codeNamespaceNotFound, err)
+				return
+			}
+
+			h(kv, namespaceProposer{p: p, namespace: ns}).ServeHTTP(w, r)
+		})
+	}
+}
+
+type namespaceOutput struct {
+	Name     string       `json:"name"`
+	Keys     int64        `json:"keys"`
+	Bytes    int64        `json:"bytes"`
+	Quota    *quotaOutput `json:"quota,omitempty"`
+	Revision int64        `json:"revision"`
+}
+
+func newNamespaceOutput(info store.NamespaceInfo) namespaceOutput {
+	out := namespaceOutput{
+		Name:     info.Name,
+		Keys:     info.Usage.Keys,
+		Bytes:    info.Usage.Bytes,
+		Revision: info.Revision,
+	}
+	if info.Quota != (store.Quota{}) {
+		q := newQuotaOutput(info.Quota)
+		out.Quota = &q
+	}
+
+	return out
+}
+
+// @title List namespaces
+// @description lists the namespaces, the size of their key spaces and their quotas
+// @success 200
+// @router /namespaces [get]
+func NewNamespacesListHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := make([]namespaceOutput, 0)
+		for _, info := range s.Namespaces() {
+			out = append(out, newNamespaceOutput(info))
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
+
+// @title Get namespace
+// @description returns the size, quota and revision of a namespace's key space
+// @param ns path string true "namespace"
+// @success 200
+// @failure 404
+// @router /namespaces/{ns} [get]
+func NewNamespaceGetHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, err := s.NamespaceInfo(r.PathValue("ns"))
+		if err != nil {
+			notFound(l, w, codeNamespaceNotFound, err)
+			return
+		}
+
+		writeJSON(l, newNamespaceOutput(info), w, http.StatusOK)
+	})
+}
+
+// @title Create namespace
+// @description creates an empty namespace, served under /ns/{ns} with its own keys, revisions, leases and watches
+// @accept json
+// @param input body api.NewNamespaceCreateHandler.input true "Namespace"
+// @success 201
+// @failure 409
+// @router /namespaces [post]
+func NewNamespaceCreateHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	type input struct {
+		Name string `json:"name" validate:"required"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		_, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action: internalRaft.NamespaceCreate,
+			Key:    in.Name,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, store.NamespaceExistsError):
+				conflict(l, w, codeNamespaceExists, err)
+			case errors.Is(err, store.InvalidNamespaceError):
+				badRequest(l, w, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		writeJSON(l, namespaceOutput{Name: in.Name}, w, http.StatusCreated)
+	})
+}
+
+// @title Delete namespace
+// @description deletes a namespace with every key and lease in it, ending its watches
+// @param ns path string true "namespace"
+// @success 204
+// @failure 404
+// @router /namespaces/{ns} [delete]
+func NewNamespaceDeleteHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		_, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action: internalRaft.NamespaceDelete,
+			Key:    r.PathValue("ns"),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, store.NamespaceNotFoundError):
+				notFound(l, w, codeNamespaceNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
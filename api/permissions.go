@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+// accessFunc lists what a request touches, in the namespace of its path. An
+// empty list only needs the caller to be authenticated.
+type accessFunc func(r *http.Request) ([]store.Access, error)
+
+func authenticatedAccess(*http.Request) ([]store.Access, error) {
+	return nil, nil
+}
+
+func adminAccess(*http.Request) ([]store.Access, error) {
+	return []store.Access{{Admin: true}}, nil
+}
+
+// pathKeyAccess covers the key named in the path.
+func pathKeyAccess(write bool) accessFunc {
+	return func(r *http.Request) ([]store.Access, error) {
+		return []store.Access{store.KeyAccess(r.PathValue("key"), write)}, nil
+	}
+}
+
+// queryRangeAccess covers the prefix, or [start, end), of the query, as read
+// by the list and delete range handlers.
+func queryRangeAccess(write bool) accessFunc {
+	return func(r *http.Request) ([]store.Access, error) {
+		q := r.URL.Query()
+		if q.Has("prefix") {
+			return []store.Access{store.PrefixAccess(q.Get("prefix"), write)}, nil
+		}
+
+		return []store.Access{{Start: q.Get("start"), End: q.Get("end"), Write: write}}, nil
+	}
+}
+
+func watchAccess(r *http.Request) ([]store.Access, error) {
+	q := r.URL.Query()
+	if q.Has("prefix") {
+		return []store.Access{store.PrefixAccess(q.Get("prefix"), false)}, nil
+	}
+
+	return []store.Access{store.KeyAccess(q.Get("key"), false)}, nil
+}
+
+// namedPrefixAccess covers the keys of a lock, semaphore or election.
+func namedPrefixAccess(prefix func(string) string, write bool) accessFunc {
+	return func(r *http.Request) ([]store.Access, error) {
+		return []store.Access{store.PrefixAccess(prefix(r.PathValue("name")), write)}, nil
+	}
+}
+
+// leaseAccess covers the use of the lease named in the path. Unknown leases
+// are left for the handler to answer, the owner being checked again as the
+// proposal applies.
+func leaseAccess(s *store.Store) accessFunc {
+	return func(r *http.Request) ([]store.Access, error) {
+		id, err := readLeaseID(r)
+		if err != nil {
+			return nil, nil
+		}
+
+		kv, err := keyspaceOf(s, r)
+		if err != nil {
+			return nil, nil
+		}
+
+		lease, err := kv.GetLease(id)
+		if err != nil {
+			return nil, nil
+		}
+
+		return []store.Access{lease.UseAccess()}, nil
+	}
+}
+
+// bodyLeasesAccess covers the leases leases finds in the JSON body, keys
+// being attached to them. Unknown leases are only usable by root, so one
+// granted to someone else in the meantime is never attached to.
+func bodyLeasesAccess(s *store.Store, leases func(data []byte) []int64) accessFunc {
+	return func(r *http.Request) ([]store.Access, error) {
+		data, err := readBody(r)
+		if err != nil {
+			return nil, err
+		}
+
+		kv, err := keyspaceOf(s, r)
+		if err != nil {
+			return nil, nil
+		}
+
+		res := make([]store.Access, 0)
+		for _, id := range leases(data) {
+			if id == 0 {
+				continue
+			}
+
+			lease, err := kv.GetLease(id)
+			if err != nil {
+				lease = store.Lease{ID: id}
+			}
+			res = append(res, lease.UseAccess())
+		}
+
+		return res, nil
+	}
+}
+
+// bodyKeysAccess covers the keys keys finds in the JSON body, left for the
+// handler to reject when malformed.
+func bodyKeysAccess(keys func(data []byte) []string, write bool) accessFunc {
+	return func(r *http.Request) ([]store.Access, error) {
+		data, err := readBody(r)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make([]store.Access, 0)
+		for _, key := range keys(data) {
+			res = append(res, store.KeyAccess(key, write))
+		}
+
+		return res, nil
+	}
+}
+
+// allAccess covers everything each of fns lists.
+func allAccess(fns ...accessFunc) accessFunc {
+	return func(r *http.Request) ([]store.Access, error) {
+		res := make([]store.Access, 0)
+		for _, fn := range fns {
+			accesses, err := fn(r)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, accesses...)
+		}
+
+		return res, nil
+	}
+}
+
+// readBody reads the whole body, putting it back for the handler.
+func readBody(r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	return data, nil
+}
+
+func decodeBody(data []byte, v any) {
+	json.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func putKeys(data []byte) []string {
+	var in struct {
+		Key string `json:"key"`
+	}
+	decodeBody(data, &in)
+
+	return []string{in.Key}
+}
+
+func multiGetKeys(data []byte) []string {
+	var in struct {
+		Keys []string `json:"keys"`
+	}
+	decodeBody(data, &in)
+
+	return in.Keys
+}
+
+func batchKeys(data []byte) []string {
+	var in struct {
+		Operations []struct {
+			Key string `json:"key"`
+		} `json:"operations"`
+	}
+	decodeBody(data, &in)
+
+	res := make([]string, 0, len(in.Operations))
+	for _, op := range in.Operations {
+		res = append(res, op.Key)
+	}
+
+	return res
+}
+
+func leaseField(data []byte) []int64 {
+	var in struct {
+		Lease int64 `json:"lease"`
+	}
+	decodeBody(data, &in)
+
+	return []int64{in.Lease}
+}
+
+func batchLeases(data []byte) []int64 {
+	var in struct {
+		Operations []struct {
+			Lease int64 `json:"lease"`
+		} `json:"operations"`
+	}
+	decodeBody(data, &in)
+
+	res := make([]int64, 0, len(in.Operations))
+	for _, op := range in.Operations {
+		res = append(res, op.Lease)
+	}
+
+	return res
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+const noSpaceAlarm = "NOSPACE"
+
+type quotaOutput struct {
+	MaxKeys  int64 `json:"max_keys"`
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+func newQuotaOutput(q store.Quota) quotaOutput {
+	return quotaOutput{MaxKeys: q.MaxKeys, MaxBytes: q.MaxBytes}
+}
+
+type quotaInput struct {
+	MaxKeys  int64 `json:"max_keys"  validate:"gte=0"`
+	MaxBytes int64 `json:"max_bytes" validate:"gte=0"`
+}
+
+// @title Cluster quota
+// @description returns the quota of the whole cluster, namespaces included, along with its usage
+// @success 200
+// @router /quota [get]
+func NewQuotaGetHandler(l *slog.Logger, s *store.Store) http.Handler {
+	type output struct {
+		Quota quotaOutput `json:"quota"`
+		Keys  int64       `json:"keys"`
+		Bytes int64       `json:"bytes"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usage := s.TotalUsage()
+		writeJSON(l, output{
+			Quota: newQuotaOutput(s.Quota("")),
+			Keys:  usage.Keys,
+			Bytes: usage.Bytes,
+		}, w, http.StatusOK)
+	})
+}
+
+// @title Set quota
+// @description sets the key count and byte quotas of the cluster, or of the namespace in the path. Zero leaves a dimension unlimited. Writes exceeding a quota fail and raise a NOSPACE alarm
+// @accept json
+// @param input body api.quotaInput true "Quota"
+// @success 200
+// @router /quota [put]
+// @router /namespaces/{ns}/quota [put]
+func NewQuotaSetHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in quotaInput
+		if err := readJSON(l, r, &in); err != nil {
+			badRequest(l, w, err)
+			return
+		}
+
+		if !validateInput(l, r, w, in) {
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		q := store.Quota{MaxKeys: in.MaxKeys, MaxBytes: in.MaxBytes}
+		_, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action:    internalRaft.QuotaSet,
+			Namespace: r.PathValue("ns"),
+			Quota:     &q,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, store.NamespaceNotFoundError):
+				notFound(l, w, codeNamespaceNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		writeJSON(l, newQuotaOutput(q), w, http.StatusOK)
+	})
+}
+
+type alarmOutput struct {
+	Alarm     string      `json:"alarm"`
+	Namespace string      `json:"namespace,omitempty"`
+	Quota     quotaOutput `json:"quota"`
+}
+
+// @title List alarms
+// @description lists the raised alarms. A NOSPACE alarm without namespace covers the whole cluster
+// @success 200
+// @router /alarms [get]
+func NewAlarmsListHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := make([]alarmOutput, 0)
+		for _, alarm := range s.Alarms() {
+			out = append(out, alarmOutput{
+				Alarm:     noSpaceAlarm,
+				Namespace: alarm.Namespace,
+				Quota:     newQuotaOutput(alarm.Quota),
+			})
+		}
+
+		writeJSON(l, out, w, http.StatusOK)
+	})
+}
+
+// @title Disarm alarm
+// @description disarms the NOSPACE alarm of a namespace, or of the cluster when none is given, letting writes through until a quota is hit again
+// @accept json
+// @param input body api.NewAlarmDisarmHandler.input false "Namespace"
+// @success 204
+// @failure 404
+// @router /alarms/disarm [post]
+func NewAlarmDisarmHandler(l *slog.Logger, p internalRaft.Proposer) http.Handler {
+	type input struct {
+		Namespace string `json:"namespace"`
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in input
+		if err := readJSON(l, r, &in); err != nil && !errors.Is(err, io.EOF) {
+			badRequest(l, w, err)
+			return
+		}
+
+		ctx, cancel := proposalContext(r)
+		defer cancel()
+
+		_, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action:    internalRaft.AlarmDisarm,
+			Namespace: in.Namespace,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, store.AlarmNotFoundError):
+				notFound(l, w, codeAlarmNotFound, err)
+			case errors.Is(err, store.NamespaceNotFoundError):
+				notFound(l, w, codeNamespaceNotFound, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
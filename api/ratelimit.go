@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/pablovarg/distributed-key-value-store/ratelimit"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+// rateLimiter limits HTTP clients with the limiter shared by every protocol.
+// Clients are told apart by the user of their token when auth is enabled, by
+// their IP otherwise.
+type rateLimiter struct {
+	s          *store.Store
+	limiter    *ratelimit.Limiter
+	peerSecret string
+}
+
+// rateLimitMiddleware answers 429 to clients going over the rate limit of
+// the route they call.
+func rateLimitMiddleware(l *slog.Logger, s *store.Store, conf Config) func(http.Handler) http.Handler {
+	rl := &rateLimiter{
+		s:          s,
+		limiter:    conf.Limiter,
+		peerSecret: conf.PeerSecret,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := rl.limiter.Limit(r.Pattern)
+			if limit.Unlimited() || rl.forwardedByMember(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := rl.clientOf(r)
+			if ok, wait := rl.limiter.Take(r.Pattern, client); !ok {
+				l.Info("http rate limited", "path", r.URL.String(), "method", r.Method, "client", client)
+				tooManyRequests(l, w, wait, fmt.Errorf("rate limit of %g requests a second exceeded", limit.Rate))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientOf identifies the caller by its user when it holds a valid token,
+// by its IP otherwise.
+func (rl *rateLimiter) clientOf(r *http.Request) string {
+	if rl.s.AuthEnabled() {
+		if principal, err := authenticate(rl.s, r); err == nil {
+			return "user:" + principal.User
+		}
+	}
+
+	return "ip:" + remoteIP(r)
+}
+
+// forwardedByMember reports whether a follower forwarded the request, which
+// it already limited for the client that sent it. Only the secret the members
+// share marks a forwarded request, neither the hops header nor the address it
+// comes from can be trusted.
+func (rl *rateLimiter) forwardedByMember(r *http.Request) bool {
+	if rl.peerSecret == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(peerSecretHeader)), []byte(rl.peerSecret)) == 1
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+const octetStream = "application/octet-stream"
+
+// readRawBody reads the whole request body, answering 413 without reading
+// further once it exceeds limit bytes.
+func readRawBody(l *slog.Logger, r *http.Request, w http.ResponseWriter, limit int) ([]byte, bool) {
+	tooLarge := fmt.Errorf("request body exceeds %d bytes", limit)
+	if r.ContentLength > int64(limit) {
+		payloadTooLarge(l, w, tooLarge)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(limit)))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			payloadTooLarge(l, w, tooLarge)
+		default:
+			badRequest(l, w, err)
+		}
+		return nil, false
+	}
+
+	return body, true
+}
+
+// accepts reports whether the Accept header of r explicitly lists mediaType.
+// Wildcards do not count, so clients keep getting JSON by default.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for part := range strings.SplitSeq(accept, ",") {
+			t, _, err := mime.ParseMediaType(part)
+			if err == nil && t == mediaType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// writeRaw writes the value of e as the body, under the content type it was
+// stored with.
+func writeRaw(w http.ResponseWriter, e store.Entry) {
+	contentType := e.ContentType
+	if contentType == "" {
+		contentType = octetStream
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(e.Value)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(e.Value)
+}
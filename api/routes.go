@@ -6,19 +6,117 @@ import (
 
 	"go.etcd.io/raft/v3"
 
+	"github.com/pablovarg/distributed-key-value-store/concurrency"
 	_ "github.com/pablovarg/distributed-key-value-store/docs"
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
 	"github.com/pablovarg/distributed-key-value-store/store"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/swaggo/http-swagger"
 )
 
-func routes(l *slog.Logger, n raft.Node, s store.Store) *http.ServeMux {
+func routes(
+	l *slog.Logger,
+	n raft.Node,
+	p internalRaft.Proposer,
+	c internalRaft.Cluster,
+	s *store.Store,
+	members *internalRaft.Members,
+	conf Config,
+) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	all := hitLoggingMiddleware(l)
-	mux.Handle("POST /values", all(NewPutHandler(l, n)))
-	mux.Handle("GET /values/{key}", all(NewGetHandler(l, n, s)))
-	mux.Handle("DELETE /values/{key}", all(NewDeleteHandler(l, n)))
-	mux.Handle("GET /status", all(NewStatusHandler(l, n)))
+	logging, limit := hitLoggingMiddleware(l), rateLimitMiddleware(l, s, conf)
+	bodyLimit := bodyLimitMiddleware(l, conf.MaxRequestBytes)
+	all := func(h http.Handler) http.Handler {
+		return metricsMiddleware(tracingMiddleware(logging(limit(bodyLimit(h)))))
+	}
+	leader := forwardToLeaderMiddleware(l, n, members, conf.PeerSecret)
+	authz := authMiddleware(l, s)
+	read, write := pathKeyAccess(false), pathKeyAccess(true)
+	admin, authenticated := authz(adminAccess), authz(authenticatedAccess)
+	// The key space routes are served at the root and in every namespace.
+	keyspace := keyspaceMiddleware(l, s, p)
+	withProposer := func(h func(*slog.Logger, internalRaft.Proposer) http.Handler) http.Handler {
+		return keyspace(func(_ *store.Store, p internalRaft.Proposer) http.Handler { return h(l, p) })
+	}
+	withStore := func(h func(*slog.Logger, *store.Store) http.Handler) http.Handler {
+		return keyspace(func(s *store.Store, _ internalRaft.Proposer) http.Handler { return h(l, s) })
+	}
+	withBoth := func(h func(*slog.Logger, internalRaft.Proposer, *store.Store) http.Handler) http.Handler {
+		return keyspace(func(s *store.Store, p internalRaft.Proposer) http.Handler { return h(l, p, s) })
+	}
+	withConf := func(h func(*slog.Logger, internalRaft.Proposer, Config) http.Handler) http.Handler {
+		return keyspace(func(_ *store.Store, p internalRaft.Proposer) http.Handler { return h(l, p, conf) })
+	}
+	for _, root := range []string{"", "/ns/{ns}"} {
+		mux.Handle("POST "+root+"/values", all(authz(allAccess(bodyKeysAccess(putKeys, true), bodyLeasesAccess(s, leaseField)))(leader(withProposer(NewPutHandler)))))
+		mux.Handle("GET "+root+"/values", all(authz(queryRangeAccess(false))(leader(withStore(NewListHandler)))))
+		mux.Handle("POST "+root+"/values/_mget", all(authz(bodyKeysAccess(multiGetKeys, false))(leader(withStore(NewMultiGetHandler)))))
+		mux.Handle("GET "+root+"/values/{key}", all(authz(read)(leader(withStore(NewGetHandler)))))
+		mux.Handle("PUT "+root+"/values/{key}", all(authz(write)(leader(withConf(NewRawPutHandler)))))
+		mux.Handle("DELETE "+root+"/values", all(authz(queryRangeAccess(true))(leader(withProposer(NewDeleteRangeHandler)))))
+		mux.Handle("DELETE "+root+"/values/{key}", all(authz(write)(leader(withProposer(NewDeleteHandler)))))
+		mux.Handle("POST "+root+"/values/{key}/incr", all(authz(write)(leader(withProposer(NewIncrementHandler)))))
+		mux.Handle("POST "+root+"/values/{key}/decr", all(authz(write)(leader(withProposer(NewDecrementHandler)))))
+		mux.Handle("POST "+root+"/batch", all(authz(allAccess(bodyKeysAccess(batchKeys, true), bodyLeasesAccess(s, batchLeases)))(leader(withConf(NewBatchHandler)))))
+		mux.Handle("POST "+root+"/leases", all(authenticated(leader(withBoth(NewLeaseGrantHandler)))))
+		mux.Handle("GET "+root+"/leases/{id}", all(authz(leaseAccess(s))(leader(withStore(NewLeaseGetHandler)))))
+		mux.Handle("POST "+root+"/leases/{id}/keepalive", all(authz(leaseAccess(s))(leader(withBoth(NewLeaseKeepAliveHandler)))))
+		mux.Handle("DELETE "+root+"/leases/{id}", all(authz(leaseAccess(s))(leader(withProposer(NewLeaseRevokeHandler)))))
+		mux.Handle("GET "+root+"/watch", all(authz(watchAccess)(withStore(NewWatchHandler))))
+	}
+	mux.Handle("GET /ns/{ns}/snapshot", all(admin(leader(withStore(NewSnapshotHandler)))))
+	mux.Handle("GET /namespaces", all(authenticated(leader(NewNamespacesListHandler(l, s)))))
+	mux.Handle("GET /namespaces/{ns}", all(authenticated(leader(NewNamespaceGetHandler(l, s)))))
+	mux.Handle("POST /namespaces", all(admin(leader(NewNamespaceCreateHandler(l, p)))))
+	mux.Handle("DELETE /namespaces/{ns}", all(admin(leader(NewNamespaceDeleteHandler(l, p)))))
+	mux.Handle("PUT /namespaces/{ns}/quota", all(admin(leader(NewQuotaSetHandler(l, p)))))
+	mux.Handle("GET /quota", all(authenticated(leader(NewQuotaGetHandler(l, s)))))
+	mux.Handle("PUT /quota", all(admin(leader(NewQuotaSetHandler(l, p)))))
+	mux.Handle("GET /alarms", all(authenticated(leader(NewAlarmsListHandler(l, s)))))
+	mux.Handle("POST /alarms/disarm", all(admin(leader(NewAlarmDisarmHandler(l, p)))))
+
+	lock := authz(namedPrefixAccess(concurrency.LockPrefix, true))
+	semaphore := authz(namedPrefixAccess(concurrency.SemaphorePrefix, true))
+	campaign := authz(namedPrefixAccess(concurrency.ElectionPrefix, true))
+	observe := authz(namedPrefixAccess(concurrency.ElectionPrefix, false))
+	// Acquiring attaches a participant key to the lease of the body.
+	acquire := func(prefix func(string) string) func(http.Handler) http.Handler {
+		return authz(allAccess(namedPrefixAccess(prefix, true), bodyLeasesAccess(s, leaseField)))
+	}
+	mux.Handle("POST /concurrency/locks/{name}/acquire", all(acquire(concurrency.LockPrefix)(leader(NewLockHandler(l, p, s)))))
+	mux.Handle("POST /concurrency/locks/{name}/release", all(lock(leader(NewUnlockHandler(l, p, s)))))
+	mux.Handle("POST /concurrency/semaphores/{name}/acquire", all(acquire(concurrency.SemaphorePrefix)(leader(NewSemaphoreAcquireHandler(l, p, s)))))
+	mux.Handle("POST /concurrency/semaphores/{name}/release", all(semaphore(leader(NewSemaphoreReleaseHandler(l, p, s)))))
+	mux.Handle("POST /concurrency/elections/{name}/campaign", all(acquire(concurrency.ElectionPrefix)(leader(NewCampaignHandler(l, p, s)))))
+	mux.Handle("POST /concurrency/elections/{name}/proclaim", all(campaign(leader(NewProclaimHandler(l, p, s)))))
+	mux.Handle("POST /concurrency/elections/{name}/resign", all(campaign(leader(NewResignHandler(l, p, s)))))
+	mux.Handle("GET /concurrency/elections/{name}/leader", all(observe(leader(NewElectionLeaderHandler(l, s)))))
+	mux.Handle("GET /concurrency/elections/{name}/observe", all(observe(NewElectionObserveHandler(l, s))))
+
+	mux.Handle("GET /members", all(authenticated(NewMembersListHandler(l, n, members))))
+	mux.Handle("POST /members", all(admin(leader(NewMemberAddHandler(l, c)))))
+	mux.Handle("DELETE /members/{id}", all(admin(leader(NewMemberRemoveHandler(l, c)))))
+	mux.Handle("POST /leader/transfer", all(admin(leader(NewLeaderTransferHandler(l, c)))))
+	mux.Handle("GET /snapshot", all(admin(leader(NewSnapshotHandler(l, s)))))
+	mux.Handle("GET /status", all(authenticated(NewStatusHandler(l, n))))
+	mux.Handle("GET /cluster", all(authenticated(NewClusterHandler(l, n, c, s, members, conf))))
+
+	mux.Handle("GET /auth/status", all(NewAuthStatusHandler(l, s)))
+	mux.Handle("POST /auth/enable", all(admin(leader(NewAuthEnableHandler(l, p)))))
+	mux.Handle("POST /auth/disable", all(admin(leader(NewAuthDisableHandler(l, p)))))
+	mux.Handle("GET /auth/users", all(admin(leader(NewUsersListHandler(l, s)))))
+	mux.Handle("GET /auth/users/{name}", all(admin(leader(NewUserGetHandler(l, s)))))
+	mux.Handle("PUT /auth/users/{name}", all(admin(leader(NewUserPutHandler(l, p)))))
+	mux.Handle("DELETE /auth/users/{name}", all(admin(leader(NewUserDeleteHandler(l, p)))))
+	mux.Handle("POST /auth/users/{name}/tokens", all(admin(leader(NewTokenCreateHandler(l, p)))))
+	mux.Handle("DELETE /auth/tokens/{id}", all(admin(leader(NewTokenRevokeHandler(l, p)))))
+	mux.Handle("GET /auth/roles", all(admin(leader(NewRolesListHandler(l, s)))))
+	mux.Handle("GET /auth/roles/{name}", all(admin(leader(NewRoleGetHandler(l, s)))))
+	mux.Handle("PUT /auth/roles/{name}", all(admin(leader(NewRolePutHandler(l, p)))))
+	mux.Handle("DELETE /auth/roles/{name}", all(admin(leader(NewRoleDeleteHandler(l, p)))))
+
+	mux.Handle("GET /metrics", promhttp.Handler())
 
 	mux.HandleFunc(
 		"/swagger-ui/",
@@ -5,15 +5,48 @@ import (
 	"net/http"
 	"time"
 
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/ratelimit"
 	"github.com/pablovarg/distributed-key-value-store/store"
 	"go.etcd.io/raft/v3"
 )
 
+// Config holds the limits enforced by the API and what it reports about the
+// node.
+type Config struct {
+	MaxBatchOperations int
+	// MaxBatchBytes bounds the encoded batch entry, it should not exceed the
+	// raft node's MaxSizePerMsg.
+	MaxBatchBytes int
+	// MaxRequestBytes bounds every request body, checked before reading a
+	// raw put whole and proposing it.
+	MaxRequestBytes int
+	// Limiter holds the rate limits of each client on each route, shared
+	// with the gRPC and Redis servers. Nil leaves requests unlimited.
+	Limiter *ratelimit.Limiter
+	// PeerSecret, shared by every member, marks the requests a follower
+	// forwards to the leader, which are not limited again. When empty, the
+	// leader limits them too.
+	PeerSecret string
+	// Version and StartedAt are reported by GET /cluster.
+	Version   string
+	StartedAt time.Time
+}
+
 // @title Key Value store API
 // @version 1.0
 // @description This API provides a simple interface for storing, retrieving, updating, and deleting key-value pairs. It supports basic CRUD operations, enabling clients to efficiently manage data. Keys are unique strings, and values can be any valid JSON object
-func NewHTTPServer(l *slog.Logger, addr string, n raft.Node, s store.Store) *http.Server {
-	mux := routes(l, n, s)
+func NewHTTPServer(
+	l *slog.Logger,
+	addr string,
+	n raft.Node,
+	p internalRaft.Proposer,
+	c internalRaft.Cluster,
+	s *store.Store,
+	members *internalRaft.Members,
+	conf Config,
+) *http.Server {
+	mux := routes(l, n, p, c, s, members, conf)
 
 	srv := &http.Server{
 		Addr:         addr,
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+const watchProgressInterval = 10 * time.Second
+
+type watchEvent struct {
+	Type           string `json:"type"`
+	Key            string `json:"key,omitempty"`
+	Value          []byte `json:"value,omitempty"`
+	CreateRevision int64  `json:"create_revision,omitempty"`
+	Version        int64  `json:"version,omitempty"`
+	Revision       int64  `json:"revision,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+func newWatchEvent(e store.Event) watchEvent {
+	return watchEvent{
+		Type:           e.Type.String(),
+		Key:            e.Entry.Key,
+		Value:          e.Entry.Value,
+		CreateRevision: e.Entry.CreateRevision,
+		Version:        e.Entry.Version,
+		Revision:       e.Entry.ModRevision,
+	}
+}
+
+// @title Watch
+// @description streams changes on a key or prefix, as server-sent events when requested through the Accept header and as newline delimited JSON otherwise
+// @param key query string false "key to watch"
+// @param prefix query string false "prefix to watch"
+// @param start_revision query int false "revision to resume from"
+// @param progress_notify query bool false "periodically report the current revision"
+// @success 200
+// @router /watch [get]
+func NewWatchHandler(l *slog.Logger, s *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		if q.Has("key") == q.Has("prefix") {
+			badRequest(l, w, errors.New("exactly one of key or prefix is required"))
+			return
+		}
+
+		key := q.Get("key")
+		prefix := q.Has("prefix")
+		if prefix {
+			key = q.Get("prefix")
+		}
+
+		sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+		var startRevision int64
+		if lastID := r.Header.Get("Last-Event-ID"); sse && lastID != "" {
+			id, err := strconv.ParseInt(lastID, 10, 64)
+			if err != nil {
+				badRequest(l, w, fmt.Errorf("invalid Last-Event-ID: %w", err))
+				return
+			}
+			startRevision = id + 1
+		}
+		if q.Has("start_revision") {
+			rev, err := strconv.ParseInt(q.Get("start_revision"), 10, 64)
+			if err != nil || rev < 0 {
+				badRequest(l, w, errors.New("start_revision must be a non negative integer"))
+				return
+			}
+			startRevision = rev
+		}
+
+		progress := false
+		if q.Has("progress_notify") {
+			v, err := strconv.ParseBool(q.Get("progress_notify"))
+			if err != nil {
+				badRequest(l, w, errors.New("progress_notify must be a boolean"))
+				return
+			}
+			progress = v
+		}
+
+		watcher, err := s.Watch(key, prefix, startRevision)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.RevisionCompactedError):
+				gone(l, w, codeRevisionCompacted, err)
+			default:
+				serverError(l, r, w, err)
+			}
+			return
+		}
+		defer watcher.Cancel()
+
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			serverError(l, r, w, err)
+			return
+		}
+
+		if sse {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(ev watchEvent) error {
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+
+			if sse {
+				if ev.Revision > 0 {
+					fmt.Fprintf(w, "id: %d\n", ev.Revision)
+				}
+				_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", strings.ToLower(ev.Type), data)
+			} else {
+				_, err = fmt.Fprintf(w, "%s\n", data)
+			}
+			if err != nil {
+				return err
+			}
+
+			return rc.Flush()
+		}
+
+		if err := rc.Flush(); err != nil {
+			l.Debug("watch flush failed", "err", err)
+			return
+		}
+
+		var progressC <-chan time.Time
+		if progress {
+			ticker := time.NewTicker(watchProgressInterval)
+			defer ticker.Stop()
+			progressC = ticker.C
+		}
+
+		for {
+			select {
+			case e, ok := <-watcher.Events():
+				if !ok {
+					if err := watcher.Err(); err != nil {
+						l.Info("watcher closed by store", "key", key, "prefix", prefix, "err", err)
+						send(watchEvent{Type: "ERROR", Error: err.Error()})
+					}
+					return
+				}
+
+				if err := send(newWatchEvent(e)); err != nil {
+					l.Debug("watch client gone", "err", err)
+					return
+				}
+			case <-progressC:
+				s.RequestProgress(watcher)
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+}
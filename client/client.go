@@ -0,0 +1,281 @@
+// Package client is a Go client for the key-value store HTTP API. It finds
+// the leader among the configured endpoints, retries idempotent requests with
+// backoff and reports failures through typed errors.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff     = 2 * time.Second
+)
+
+type Config struct {
+	// Endpoints are the base URLs of the cluster members, like
+	// http://node:8000.
+	Endpoints []string
+	// HTTPClient defaults to a client with a 30 seconds timeout. Streaming
+	// calls such as Watch use a copy of it without timeout.
+	HTTPClient *http.Client
+	// MaxRetries bounds the retries of idempotent requests, 3 by default.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled on every
+	// following one.
+	RetryBackoff time.Duration
+	// Token is sent as a bearer token on every request, as needed once the
+	// cluster enables auth.
+	Token string
+}
+
+type Client struct {
+	endpoints    []string
+	httpClient   *http.Client
+	streamClient *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	token        string
+
+	mu     sync.Mutex
+	leader string
+}
+
+func New(conf Config) (*Client, error) {
+	if len(conf.Endpoints) == 0 {
+		return nil, errors.New("client: at least one endpoint is required")
+	}
+
+	c := &Client{
+		endpoints:    make([]string, 0, len(conf.Endpoints)),
+		httpClient:   conf.HTTPClient,
+		maxRetries:   conf.MaxRetries,
+		retryBackoff: conf.RetryBackoff,
+		token:        conf.Token,
+	}
+	for _, e := range conf.Endpoints {
+		c.endpoints = append(c.endpoints, strings.TrimSuffix(e, "/"))
+	}
+
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+	c.streamClient = &streamClient
+	if c.maxRetries == 0 {
+		c.maxRetries = defaultMaxRetries
+	}
+	if c.retryBackoff == 0 {
+		c.retryBackoff = defaultRetryBackoff
+	}
+
+	return c, nil
+}
+
+// Status is the raft state reported by a member.
+type Status struct {
+	Endpoint  string
+	ID        uint64
+	Lead      uint64
+	Term      uint64
+	Commit    uint64
+	Applied   uint64
+	RaftState string
+}
+
+func (s Status) IsLeader() bool {
+	return s.ID != 0 && s.ID == s.Lead
+}
+
+// Status returns the raft state of the member behind endpoint.
+func (c *Client) Status(ctx context.Context, endpoint string) (Status, error) {
+	// IDs are reported in hexadecimal.
+	var res struct {
+		ID        string `json:"id"`
+		Lead      string `json:"lead"`
+		Term      uint64 `json:"term"`
+		Commit    uint64 `json:"commit"`
+		Applied   uint64 `json:"applied"`
+		RaftState string `json:"raftState"`
+	}
+	if err := c.send(ctx, endpoint, http.MethodGet, "/status", nil, &res); err != nil {
+		return Status{}, err
+	}
+
+	id, err := strconv.ParseUint(res.ID, 16, 64)
+	if err != nil {
+		return Status{}, fmt.Errorf("client: invalid status id %q: %w", res.ID, err)
+	}
+	lead, err := strconv.ParseUint(res.Lead, 16, 64)
+	if err != nil {
+		return Status{}, fmt.Errorf("client: invalid status lead %q: %w", res.Lead, err)
+	}
+
+	return Status{
+		Endpoint:  endpoint,
+		ID:        id,
+		Lead:      lead,
+		Term:      res.Term,
+		Commit:    res.Commit,
+		Applied:   res.Applied,
+		RaftState: res.RaftState,
+	}, nil
+}
+
+// Leader asks every endpoint for its status and returns the leader's one.
+func (c *Client) Leader(ctx context.Context) (string, error) {
+	var lastErr error = ErrNoLeader
+	for _, e := range c.endpoints {
+		status, err := c.Status(ctx, e)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if status.IsLeader() {
+			c.mu.Lock()
+			c.leader = e
+			c.mu.Unlock()
+
+			return e, nil
+		}
+	}
+
+	return "", lastErr
+}
+
+// endpoint returns the cached leader, falling back to the first endpoint
+// while no leader is known. Followers forward to the leader themselves.
+func (c *Client) endpoint(ctx context.Context) string {
+	c.mu.Lock()
+	leader := c.leader
+	c.mu.Unlock()
+
+	if leader != "" {
+		return leader
+	}
+
+	if e, err := c.Leader(ctx); err == nil {
+		return e
+	}
+
+	return c.endpoints[0]
+}
+
+func (c *Client) forgetLeader() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.leader = ""
+}
+
+// do sends a request to the leader. Idempotent requests are retried with
+// exponential backoff on transport errors and retryable statuses, looking the
+// leader up again before every retry. A busy leader is kept and waited for
+// as long as it asked.
+func (c *Client) do(ctx context.Context, idempotent bool, method, path string, in, out any) error {
+	attempts := 1
+	if idempotent {
+		attempts += c.maxRetries
+	}
+
+	backoff := c.retryBackoff
+	var err error
+	for attempt := range attempts {
+		if attempt > 0 {
+			wait := backoff
+			var e *Error
+			if errors.As(err, &e) {
+				wait = max(wait, e.RetryAfter)
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return errors.Join(ctx.Err(), err)
+			}
+			backoff = min(2*backoff, maxRetryBackoff)
+		}
+
+		err = c.send(ctx, c.endpoint(ctx), method, path, in, out)
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if !busy(err) {
+			c.forgetLeader()
+		}
+	}
+
+	return err
+}
+
+func (c *Client) send(ctx context.Context, endpoint, method, path string, in, out any) error {
+	res, err := c.open(ctx, c.httpClient, endpoint, method, path, in)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, res.Body)
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decoding response: %w", err)
+	}
+
+	return nil
+}
+
+// open sends the request and returns the response when its status is a
+// success, leaving the caller to close the body.
+func (c *Client) open(
+	ctx context.Context,
+	hc *http.Client,
+	endpoint, method, path string,
+	in any,
+) (*http.Response, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, &Error{Err: err}
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		return nil, newStatusError(res)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,348 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pablovarg/distributed-key-value-store/api"
+	"github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/raft/v3/raftpb"
+)
+
+// testTimeout bounds every call against the test cluster, leaving room for
+// the elections it waits through.
+const testTimeout = 30 * time.Second
+
+// testNode is a cluster member running in the test process.
+type testNode struct {
+	ID     uint64
+	URL    string
+	n      raft.RaftNode
+	srv    *http.Server
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// stop shuts the member down as a crash would, without handing over its
+// leadership. Its raft listener stays bound, dropping what it receives.
+func (tn *testNode) stop() {
+	tn.cancel()
+	tn.srv.Close()
+	tn.n.RaftNode.Stop()
+	tn.wg.Wait()
+}
+
+type testCluster struct {
+	nodes []*testNode
+}
+
+// startCluster starts size members talking over the loopback interface and
+// stops them when the test ends.
+func startCluster(t *testing.T, size int) *testCluster {
+	t.Helper()
+
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+	apiListeners := make([]net.Listener, size)
+	all := make([]raft.Member, size)
+	for i := range size {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		apiListeners[i] = lis
+		all[i] = raft.Member{
+			ID:        uint64(i + 1),
+			PeerAddr:  freeAddr(t),
+			ClientURL: "http://" + lis.Addr().String(),
+		}
+	}
+
+	c := &testCluster{}
+	for i, member := range all {
+		members := raft.NewMembers()
+		for _, m := range all {
+			members.Set(m)
+		}
+
+		messagesTx := make(chan raftpb.Message)
+		messagesRx := make(chan raftpb.Message)
+		s := store.NewKeyValueStore()
+		tr := raft.NewTransport(
+			l,
+			member.PeerAddr,
+			func(ID uint64) string {
+				m, _ := members.Get(ID)
+				return m.PeerAddr
+			},
+			messagesRx,
+			messagesTx,
+		)
+		n := raft.NewRaftNode(l, s, members, messagesTx, tr)
+		n.StartNode(member.ID, raft.NodeConfig{MaxSizePerMsg: 1 << 20})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		tn := &testNode{
+			ID:     member.ID,
+			URL:    member.ClientURL,
+			n:      n,
+			srv:    api.NewHTTPServer(l, "", n.RaftNode, n, n, s, members, api.Config{}),
+			cancel: cancel,
+		}
+		tn.wg.Add(3)
+		go func() {
+			defer tn.wg.Done()
+			n.Loop(ctx)
+		}()
+		go func() {
+			defer tn.wg.Done()
+			n.StepToMessages(ctx)
+		}()
+		go func() {
+			defer tn.wg.Done()
+			tn.srv.Serve(apiListeners[i])
+		}()
+		go tr.ListenAndServe(ctx)
+
+		c.nodes = append(c.nodes, tn)
+	}
+
+	t.Cleanup(func() {
+		for _, tn := range c.nodes {
+			tn.stop()
+		}
+	})
+
+	return c
+}
+
+func (c *testCluster) endpoints() []string {
+	endpoints := make([]string, 0, len(c.nodes))
+	for _, tn := range c.nodes {
+		endpoints = append(endpoints, tn.URL)
+	}
+
+	return endpoints
+}
+
+func (c *testCluster) node(URL string) *testNode {
+	for _, tn := range c.nodes {
+		if tn.URL == URL {
+			return tn
+		}
+	}
+
+	return nil
+}
+
+// freeAddr returns a loopback address nothing listens on, for the raft
+// transport to bind itself.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer lis.Close()
+
+	return lis.Addr().String()
+}
+
+// waitForLeader polls the cluster until one of the members leads it.
+func waitForLeader(t *testing.T, c *Client) string {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		leader, err := c.Leader(ctx)
+		cancel()
+		if err == nil {
+			return leader
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Fatalf("no leader elected within %s", testTimeout)
+	return ""
+}
+
+func newTestClient(t *testing.T, conf Config) *Client {
+	t.Helper()
+
+	c, err := New(conf)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	return c
+}
+
+func TestClientFindsLeader(t *testing.T) {
+	cluster := startCluster(t, 3)
+	c := newTestClient(t, Config{Endpoints: cluster.endpoints()})
+	leader := waitForLeader(t, c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	status, err := c.Status(ctx, leader)
+	if err != nil {
+		t.Fatalf("status of %s: %v", leader, err)
+	}
+	if !status.IsLeader() || status.ID != cluster.node(leader).ID {
+		t.Fatalf("%s reports %+v, want it to lead as member %d", leader, status, cluster.node(leader).ID)
+	}
+
+	if err := c.Put(ctx, "greeting", []byte("hello")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if got := c.endpoint(ctx); got != leader {
+		t.Fatalf("request sent to %s, want the leader %s", got, leader)
+	}
+
+	value, err := c.Get(ctx, "greeting")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if string(value) != "hello" {
+		t.Fatalf("got %q, want %q", value, "hello")
+	}
+}
+
+func TestClientFailsOverAfterLeaderLoss(t *testing.T) {
+	cluster := startCluster(t, 3)
+	c := newTestClient(t, Config{
+		Endpoints:    cluster.endpoints(),
+		MaxRetries:   20,
+		RetryBackoff: 200 * time.Millisecond,
+	})
+	leader := waitForLeader(t, c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := c.Put(ctx, "greeting", []byte("hello")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	cluster.node(leader).stop()
+
+	// The put waits through the election, retried against the new leader.
+	if err := c.Put(ctx, "greeting", []byte("hi")); err != nil {
+		t.Fatalf("put after the leader was lost: %v", err)
+	}
+	if got := c.endpoint(ctx); got == leader {
+		t.Fatalf("client still talks to the lost leader %s", leader)
+	}
+
+	value, err := c.Get(ctx, "greeting")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if string(value) != "hi" {
+		t.Fatalf("got %q, want %q", value, "hi")
+	}
+}
+
+// dropFirstResponse lets requests matching path reach the server but loses
+// the response of the first one, as a connection cut after the write would.
+type dropFirstResponse struct {
+	path    string
+	dropped atomic.Bool
+	sent    atomic.Int32
+}
+
+func (d *dropFirstResponse) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || req.URL.Path != d.path {
+		return res, err
+	}
+
+	d.sent.Add(1)
+	if !d.dropped.Swap(true) {
+		res.Body.Close()
+		return nil, errors.New("connection reset")
+	}
+
+	return res, nil
+}
+
+func TestClientRetriesOnlyIdempotentRequests(t *testing.T) {
+	cluster := startCluster(t, 3)
+	waitForLeader(t, newTestClient(t, Config{Endpoints: cluster.endpoints()}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	t.Run("put", func(t *testing.T) {
+		rt := &dropFirstResponse{path: "/values"}
+		c := newTestClient(t, Config{Endpoints: cluster.endpoints(), HTTPClient: &http.Client{Transport: rt}})
+
+		if err := c.Put(ctx, "greeting", []byte("hello")); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		if sent := rt.sent.Load(); sent != 2 {
+			t.Fatalf("put sent %d times, want it retried once", sent)
+		}
+	})
+
+	t.Run("increment", func(t *testing.T) {
+		rt := &dropFirstResponse{path: "/values/hits/incr"}
+		c := newTestClient(t, Config{Endpoints: cluster.endpoints(), HTTPClient: &http.Client{Transport: rt}})
+
+		if _, err := c.Increment(ctx, "hits", 1); err == nil {
+			t.Fatal("increment succeeded, want the lost response reported")
+		}
+		if sent := rt.sent.Load(); sent != 1 {
+			t.Fatalf("increment sent %d times, want it never retried", sent)
+		}
+
+		// The increment applied once, though its response was lost.
+		hits, err := c.Increment(ctx, "hits", 0)
+		if err != nil {
+			t.Fatalf("increment: %v", err)
+		}
+		if hits != 1 {
+			t.Fatalf("hits is %d, want 1", hits)
+		}
+	})
+}
+
+func TestClientKeepsBusyLeader(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"status":503,"code":"overloaded","detail":"too many proposals in flight","retryable":true}`)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// Looking the leader up again would pick the unreachable first endpoint.
+	c := newTestClient(t, Config{
+		Endpoints:    []string{"http://" + freeAddr(t), srv.URL},
+		RetryBackoff: time.Millisecond,
+	})
+	c.leader = srv.URL
+
+	if err := c.do(context.Background(), true, http.MethodPost, "/values", nil, nil); err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	if c.leader != srv.URL {
+		t.Fatalf("leader is %q after an overloaded answer, want %s kept", c.leader, srv.URL)
+	}
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type Member struct {
+	ID        uint64 `json:"id"`
+	PeerAddr  string `json:"peer_addr"`
+	ClientURL string `json:"client_url"`
+	IsLeader  bool   `json:"is_leader"`
+}
+
+func (c *Client) Members(ctx context.Context) ([]Member, error) {
+	var res struct {
+		Members []Member `json:"members"`
+	}
+	if err := c.do(ctx, true, http.MethodGet, "/members", nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Members, nil
+}
+
+// AddMember adds a member to the cluster. The node must be started with
+// JOIN=true so it waits to be added instead of bootstrapping its own cluster.
+func (c *Client) AddMember(ctx context.Context, member Member) error {
+	req := struct {
+		ID        uint64 `json:"id"`
+		PeerAddr  string `json:"peer_addr"`
+		ClientURL string `json:"client_url"`
+	}{
+		ID:        member.ID,
+		PeerAddr:  member.PeerAddr,
+		ClientURL: member.ClientURL,
+	}
+
+	return c.do(ctx, false, http.MethodPost, "/members", req, nil)
+}
+
+func (c *Client) RemoveMember(ctx context.Context, ID uint64) error {
+	return c.do(ctx, false, http.MethodDelete, "/members/"+strconv.FormatUint(ID, 10), nil, nil)
+}
+
+// TransferLeadership hands the leadership over to the member with ID.
+func (c *Client) TransferLeadership(ctx context.Context, ID uint64) error {
+	req := struct {
+		ID uint64 `json:"id"`
+	}{ID: ID}
+
+	if err := c.do(ctx, true, http.MethodPost, "/leader/transfer", req, nil); err != nil {
+		return err
+	}
+	c.forgetLeader()
+
+	return nil
+}
+
+// Snapshot streams a point-in-time snapshot of the store. The caller must
+// close it.
+func (c *Client) Snapshot(ctx context.Context) (io.ReadCloser, error) {
+	res, err := c.open(ctx, c.streamClient, c.endpoint(ctx), http.MethodGet, "/snapshot", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
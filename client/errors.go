@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrNoLeader           = errors.New("no leader available")
+	ErrOverloaded         = errors.New("server overloaded")
+	ErrRateLimited        = errors.New("rate limited")
+)
+
+// Error is returned for every failed request. It matches ErrNotFound,
+// ErrPreconditionFailed, ErrNoLeader, ErrOverloaded and ErrRateLimited
+// through errors.Is depending on the error code, or on the response status
+// when the server sent none.
+type Error struct {
+	// StatusCode is zero when the request never got a response.
+	StatusCode int
+	// Code is the stable error code reported by the server, such as
+	// key_not_found or proposal_timeout.
+	Code    string
+	Message string
+	// Retryable reports whether the server deemed the request worth
+	// sending again.
+	Retryable bool
+	// RetryAfter is how long the server asked to wait before sending the
+	// request again, zero when it did not say.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("client: %s", e.Err)
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("client: %d %s: %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("client: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newStatusError(res *http.Response) *Error {
+	e := &Error{StatusCode: res.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	var problem struct {
+		Detail    string `json:"detail"`
+		Code      string `json:"code"`
+		Retryable bool   `json:"retryable"`
+	}
+	if err := json.Unmarshal(body, &problem); err == nil && problem.Code != "" {
+		e.Code, e.Message, e.Retryable = problem.Code, problem.Detail, problem.Retryable
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	if s, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && s > 0 {
+		e.RetryAfter = time.Duration(s) * time.Second
+	}
+
+	switch e.Code {
+	case "not_leader", "no_quorum", "transfer_incomplete":
+		e.Err = ErrNoLeader
+	case "overloaded":
+		e.Err = ErrOverloaded
+	case "rate_limited":
+		e.Err = ErrRateLimited
+	case "precondition_failed":
+		e.Err = ErrPreconditionFailed
+	default:
+		// Every not found code shares the status. The others are only told
+		// by status when the server sent no code, as behind a proxy.
+		switch {
+		case res.StatusCode == http.StatusNotFound:
+			e.Err = ErrNotFound
+		case e.Code == "" && res.StatusCode == http.StatusPreconditionFailed:
+			e.Err = ErrPreconditionFailed
+		case e.Code == "" && res.StatusCode == http.StatusServiceUnavailable:
+			e.Err = ErrNoLeader
+		}
+	}
+
+	return e
+}
+
+// retryable reports whether a request may succeed if sent again, either
+// because it never got an answer or because the server said so.
+func retryable(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	if e.Code != "" {
+		return e.Retryable
+	}
+
+	switch e.StatusCode {
+	case 0, http.StatusServiceUnavailable, http.StatusGatewayTimeout, http.StatusInternalServerError:
+		return true
+	default:
+		return false
+	}
+}
+
+// busy reports whether the server refused the request to shed load, in
+// which case the leader is still the right member to retry against.
+func busy(err error) bool {
+	return errors.Is(err, ErrOverloaded) || errors.Is(err, ErrRateLimited)
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusErrorMapsCode(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusServiceUnavailable, `{"code":"no_quorum","retryable":true}`, ErrNoLeader},
+		{http.StatusServiceUnavailable, `{"code":"not_leader","retryable":true}`, ErrNoLeader},
+		{http.StatusServiceUnavailable, `{"code":"overloaded","retryable":true}`, ErrOverloaded},
+		{http.StatusTooManyRequests, `{"code":"rate_limited","retryable":true}`, ErrRateLimited},
+		{http.StatusServiceUnavailable, `{"code":"upload_interrupted","retryable":true}`, nil},
+		{http.StatusServiceUnavailable, `no healthy upstream`, ErrNoLeader},
+		{http.StatusNotFound, `{"code":"lease_not_found"}`, ErrNotFound},
+		{http.StatusPreconditionFailed, `{"code":"precondition_failed"}`, ErrPreconditionFailed},
+	}
+	sentinels := []error{ErrNotFound, ErrPreconditionFailed, ErrNoLeader, ErrOverloaded, ErrRateLimited}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(tt.status)
+		rec.WriteString(tt.body)
+
+		err := newStatusError(rec.Result())
+		for _, sentinel := range sentinels {
+			if errors.Is(err, sentinel) != (sentinel == tt.want) {
+				t.Errorf("%d %s: errors.Is(%v) is %t", tt.status, tt.body, sentinel, errors.Is(err, sentinel))
+			}
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type PutOption func(*putRequest)
+
+type putRequest struct {
+	Key        string `json:"key"`
+	Value      []byte `json:"value"`
+	TTLSeconds int64  `json:"ttl_seconds,omitempty"`
+	Lease      int64  `json:"lease,omitempty"`
+}
+
+// WithTTL makes the key expire after seconds.
+func WithTTL(seconds int64) PutOption {
+	return func(r *putRequest) {
+		r.TTLSeconds = seconds
+	}
+}
+
+// WithLease attaches the key to a lease, deleting it when the lease ends.
+func WithLease(ID int64) PutOption {
+	return func(r *putRequest) {
+		r.Lease = ID
+	}
+}
+
+func (c *Client) Put(ctx context.Context, key string, value []byte, opts ...PutOption) error {
+	req := putRequest{Key: key, Value: value}
+	for _, opt := range opts {
+		opt(&req)
+	}
+
+	return c.do(ctx, true, http.MethodPost, "/values", req, nil)
+}
+
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	var res KeyValue
+	if err := c.do(ctx, true, http.MethodGet, "/values/"+url.PathEscape(key), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return res.Value, nil
+}
+
+type Entry struct {
+	Key            string `json:"key"`
+	Value          []byte `json:"value"`
+	CreateRevision int64  `json:"create_revision"`
+	ModRevision    int64  `json:"mod_revision"`
+	Version        int64  `json:"version"`
+	Lease          int64  `json:"lease"`
+}
+
+type ListOptions struct {
+	// Prefix lists the keys starting with it. It may not be combined with
+	// Start and End.
+	Prefix string
+	// Start and End list the keys in [Start, End), an empty End leaving the
+	// range unbounded.
+	Start string
+	End   string
+	// Limit bounds the number of entries returned when positive.
+	Limit int
+}
+
+type ListResult struct {
+	Revision int64   `json:"revision"`
+	Values   []Entry `json:"values"`
+	// More reports whether Limit left keys out.
+	More bool `json:"more"`
+}
+
+// List reads the keys selected by opts, ordered by key and all at the same
+// revision. Zero opts list every key.
+func (c *Client) List(ctx context.Context, opts ListOptions) (ListResult, error) {
+	q := url.Values{}
+	switch {
+	case opts.Prefix != "":
+		q.Set("prefix", opts.Prefix)
+	case opts.Start != "" || opts.End != "":
+		q.Set("start", opts.Start)
+		if opts.End != "" {
+			q.Set("end", opts.End)
+		}
+	}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var res ListResult
+	if err := c.do(ctx, true, http.MethodGet, "/values?"+q.Encode(), nil, &res); err != nil {
+		return ListResult{}, err
+	}
+
+	return res, nil
+}
+
+type MultiGetResult struct {
+	Revision int64      `json:"revision"`
+	Values   []KeyValue `json:"values"`
+	Missing  []string   `json:"missing"`
+}
+
+// GetMany reads every key at the same revision.
+func (c *Client) GetMany(ctx context.Context, keys ...string) (MultiGetResult, error) {
+	req := struct {
+		Keys []string `json:"keys"`
+	}{Keys: keys}
+
+	var res MultiGetResult
+	if err := c.do(ctx, true, http.MethodPost, "/values/_mget", req, &res); err != nil {
+		return MultiGetResult{}, err
+	}
+
+	return res, nil
+}
+
+// Delete deletes key, failing with ErrNotFound when it does not exist. A
+// retry following a lost response may report a key it deleted as not found.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.do(ctx, true, http.MethodDelete, "/values/"+url.PathEscape(key), nil, nil)
+}
+
+type deleteRangeResult struct {
+	Deleted  int64 `json:"deleted"`
+	Revision int64 `json:"revision"`
+}
+
+// DeletePrefix deletes every key starting with prefix and returns how many
+// were removed.
+func (c *Client) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	q := url.Values{"prefix": {prefix}}
+
+	var res deleteRangeResult
+	if err := c.do(ctx, true, http.MethodDelete, "/values?"+q.Encode(), nil, &res); err != nil {
+		return 0, err
+	}
+
+	return res.Deleted, nil
+}
+
+// DeleteRange deletes every key in [start, end) and returns how many were
+// removed. An empty end leaves the range unbounded.
+func (c *Client) DeleteRange(ctx context.Context, start, end string) (int64, error) {
+	q := url.Values{"start": {start}}
+	if end != "" {
+		q.Set("end", end)
+	}
+
+	var res deleteRangeResult
+	if err := c.do(ctx, true, http.MethodDelete, "/values?"+q.Encode(), nil, &res); err != nil {
+		return 0, err
+	}
+
+	return res.Deleted, nil
+}
+
+type counterRequest struct {
+	Delta int64 `json:"delta"`
+}
+
+type counterResult struct {
+	Value int64 `json:"value"`
+}
+
+// Increment adds delta to the integer stored in key and returns the result.
+// It is never retried since it is not idempotent.
+func (c *Client) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	path, req := "/values/"+url.PathEscape(key)+"/incr", counterRequest{Delta: delta}
+	if delta < 0 {
+		path, req = "/values/"+url.PathEscape(key)+"/decr", counterRequest{Delta: -delta}
+	}
+
+	var res counterResult
+	if err := c.do(ctx, false, http.MethodPost, path, req, &res); err != nil {
+		return 0, err
+	}
+
+	return res.Value, nil
+}
+
+type BatchOp struct {
+	Op         string `json:"op"`
+	Key        string `json:"key"`
+	Value      []byte `json:"value,omitempty"`
+	TTLSeconds int64  `json:"ttl_seconds,omitempty"`
+	Lease      int64  `json:"lease,omitempty"`
+	Revision   int64  `json:"revision,omitempty"`
+}
+
+func OpPut(key string, value []byte) BatchOp {
+	return BatchOp{Op: "put", Key: key, Value: value}
+}
+
+func OpDelete(key string) BatchOp {
+	return BatchOp{Op: "delete", Key: key}
+}
+
+type BatchResult struct {
+	Revision int64 `json:"revision"`
+	Results  []struct {
+		Op       string `json:"op"`
+		Key      string `json:"key"`
+		Revision int64  `json:"revision"`
+		Found    *bool  `json:"found,omitempty"`
+	} `json:"results"`
+}
+
+// Batch applies every operation atomically.
+func (c *Client) Batch(ctx context.Context, ops ...BatchOp) (BatchResult, error) {
+	req := struct {
+		Operations []BatchOp `json:"operations"`
+	}{Operations: ops}
+
+	var res BatchResult
+	if err := c.do(ctx, false, http.MethodPost, "/batch", req, &res); err != nil {
+		return BatchResult{}, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Lease struct {
+	ID         int64     `json:"id"`
+	TTLSeconds int64     `json:"ttl_seconds"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Keys       []string  `json:"keys"`
+}
+
+func leasePath(ID int64) string {
+	return "/leases/" + strconv.FormatInt(ID, 10)
+}
+
+func (c *Client) GrantLease(ctx context.Context, ttlSeconds int64) (Lease, error) {
+	req := struct {
+		TTLSeconds int64 `json:"ttl_seconds"`
+	}{TTLSeconds: ttlSeconds}
+
+	var res Lease
+	if err := c.do(ctx, false, http.MethodPost, "/leases", req, &res); err != nil {
+		return Lease{}, err
+	}
+
+	return res, nil
+}
+
+func (c *Client) GetLease(ctx context.Context, ID int64) (Lease, error) {
+	var res Lease
+	if err := c.do(ctx, true, http.MethodGet, leasePath(ID), nil, &res); err != nil {
+		return Lease{}, err
+	}
+
+	return res, nil
+}
+
+func (c *Client) KeepAlive(ctx context.Context, ID int64) (Lease, error) {
+	var res Lease
+	if err := c.do(ctx, true, http.MethodPost, leasePath(ID)+"/keepalive", nil, &res); err != nil {
+		return Lease{}, err
+	}
+
+	return res, nil
+}
+
+// RevokeLease ends the lease and returns how many attached keys it deleted.
+func (c *Client) RevokeLease(ctx context.Context, ID int64) (int64, error) {
+	var res struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := c.do(ctx, true, http.MethodDelete, leasePath(ID), nil, &res); err != nil {
+		return 0, err
+	}
+
+	return res.Deleted, nil
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type Event struct {
+	Type           string `json:"type"`
+	Key            string `json:"key"`
+	Value          []byte `json:"value"`
+	CreateRevision int64  `json:"create_revision"`
+	Version        int64  `json:"version"`
+	Revision       int64  `json:"revision"`
+	Error          string `json:"error"`
+}
+
+type WatchOptions struct {
+	// Prefix watches every key starting with the watched key.
+	Prefix bool
+	// StartRevision replays the retained events from that revision.
+	StartRevision int64
+	// ProgressNotify asks for periodic PROGRESS events.
+	ProgressNotify bool
+}
+
+// Watch streams the changes on key until ctx is done. The channel is closed
+// when the stream ends, the last event carrying an error when the server
+// ended it.
+func (c *Client) Watch(ctx context.Context, key string, opts WatchOptions) (<-chan Event, error) {
+	q := url.Values{}
+	if opts.Prefix {
+		q.Set("prefix", key)
+	} else {
+		q.Set("key", key)
+	}
+	if opts.StartRevision > 0 {
+		q.Set("start_revision", strconv.FormatInt(opts.StartRevision, 10))
+	}
+	if opts.ProgressNotify {
+		q.Set("progress_notify", "true")
+	}
+
+	res, err := c.open(ctx, c.streamClient, c.endpoint(ctx), http.MethodGet, "/watch?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer res.Body.Close()
+
+		scanner := bufio.NewScanner(res.Body)
+		scanner.Buffer(make([]byte, 0, 64<<10), 64<<20)
+		for scanner.Scan() {
+			var e Event
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				e = Event{Type: "ERROR", Error: err.Error()}
+			}
+
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil && !errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			select {
+			case events <- Event{Type: "ERROR", Error: err.Error()}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return events, nil
+}
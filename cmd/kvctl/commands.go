@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pablovarg/distributed-key-value-store/client"
+)
+
+type command func(ctx context.Context, c *client.Client, g globals, args []string) error
+
+var commands = map[string]command{
+	"put":      putCommand,
+	"get":      getCommand,
+	"del":      delCommand,
+	"list":     listCommand,
+	"scan":     scanCommand,
+	"watch":    watchCommand,
+	"status":   statusCommand,
+	"members":  membersCommand,
+	"snapshot": snapshotCommand,
+	"leader":   leaderCommand,
+}
+
+func newFlagSet(g globals, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(g.stderr)
+
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{msg: err.Error()}
+	}
+
+	return nil
+}
+
+func parseID(s string) (uint64, error) {
+	ID, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || ID == 0 {
+		return 0, usagef("invalid member id %q", s)
+	}
+
+	return ID, nil
+}
+
+func putCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	fs := newFlagSet(g, "put")
+	ttl := fs.Int64("ttl", 0, "seconds until the key expires")
+	lease := fs.Int64("lease", 0, "lease to attach the key to")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usagef("put takes a key and an optional value")
+	}
+	key := fs.Arg(0)
+
+	var value []byte
+	if fs.NArg() == 2 && fs.Arg(1) != "-" {
+		value = []byte(fs.Arg(1))
+	} else {
+		var err error
+		if value, err = io.ReadAll(g.stdin); err != nil {
+			return fmt.Errorf("reading value from stdin: %w", err)
+		}
+	}
+
+	opts := make([]client.PutOption, 0)
+	if *ttl > 0 {
+		opts = append(opts, client.WithTTL(*ttl))
+	}
+	if *lease != 0 {
+		opts = append(opts, client.WithLease(*lease))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	if err := c.Put(ctx, key, value, opts...); err != nil {
+		return err
+	}
+
+	return printDone(g, client.KeyValue{Key: key, Value: value}, "OK")
+}
+
+func getCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	fs := newFlagSet(g, "get")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usagef("get takes a single key")
+	}
+	key := fs.Arg(0)
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	switch g.output {
+	case formatJSON:
+		return printJSON(g.stdout, client.KeyValue{Key: key, Value: value})
+	case formatRaw:
+		return printRaw(g.stdout, value)
+	default:
+		return printTable(g.stdout, []string{"KEY", "VALUE"}, [][]string{{key, string(value)}})
+	}
+}
+
+func delCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	fs := newFlagSet(g, "del")
+	prefix := fs.Bool("prefix", false, "delete every key starting with the given one")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usagef("del takes a single key")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	var deleted int64 = 1
+	if *prefix {
+		var err error
+		if deleted, err = c.DeletePrefix(ctx, fs.Arg(0)); err != nil {
+			return err
+		}
+	} else if err := c.Delete(ctx, fs.Arg(0)); err != nil {
+		return err
+	}
+
+	switch g.output {
+	case formatJSON:
+		return printJSON(g.stdout, map[string]int64{"deleted": deleted})
+	case formatRaw:
+		_, err := fmt.Fprintln(g.stdout, deleted)
+		return err
+	default:
+		_, err := fmt.Fprintf(g.stdout, "deleted %d\n", deleted)
+		return err
+	}
+}
+
+func listCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	fs := newFlagSet(g, "list")
+	limit := fs.Int("limit", 0, "maximum number of keys to list")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		return usagef("list takes an optional prefix")
+	}
+
+	return printList(ctx, c, g, client.ListOptions{Prefix: fs.Arg(0), Limit: *limit})
+}
+
+func scanCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	fs := newFlagSet(g, "scan")
+	limit := fs.Int("limit", 0, "maximum number of keys to list")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usagef("scan takes a start key and an optional end key")
+	}
+
+	return printList(ctx, c, g, client.ListOptions{Start: fs.Arg(0), End: fs.Arg(1), Limit: *limit})
+}
+
+func printList(ctx context.Context, c *client.Client, g globals, opts client.ListOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	res, err := c.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	switch g.output {
+	case formatJSON:
+		return printJSON(g.stdout, res)
+	case formatRaw:
+		values := make([][]byte, 0, len(res.Values))
+		for _, e := range res.Values {
+			values = append(values, e.Value)
+		}
+		return printRaw(g.stdout, values...)
+	default:
+		rows := make([][]string, 0, len(res.Values))
+		for _, e := range res.Values {
+			rows = append(rows, []string{
+				e.Key,
+				string(e.Value),
+				strconv.FormatInt(e.ModRevision, 10),
+				strconv.FormatInt(e.Version, 10),
+				strconv.FormatInt(e.Lease, 10),
+			})
+		}
+		return printTable(g.stdout, []string{"KEY", "VALUE", "MOD_REVISION", "VERSION", "LEASE"}, rows)
+	}
+}
+
+func watchCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	fs := newFlagSet(g, "watch")
+	prefix := fs.Bool("prefix", false, "watch every key starting with the given one")
+	rev := fs.Int64("rev", 0, "replay the changes since this revision")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usagef("watch takes a single key")
+	}
+
+	events, err := c.Watch(ctx, fs.Arg(0), client.WatchOptions{
+		Prefix:        *prefix,
+		StartRevision: *rev,
+	})
+	if err != nil {
+		return err
+	}
+
+	for e := range events {
+		if e.Type == "ERROR" {
+			return fmt.Errorf("watch ended: %s", e.Error)
+		}
+
+		switch g.output {
+		case formatJSON:
+			err = printJSON(g.stdout, e)
+		case formatRaw:
+			_, err = fmt.Fprintf(g.stdout, "%s\n", e.Value)
+		default:
+			_, err = fmt.Fprintf(g.stdout, "%s\t%s\t%s\t%d\n", e.Type, e.Key, e.Value, e.Revision)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func statusCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	fs := newFlagSet(g, "status")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	type result struct {
+		Endpoint  string `json:"endpoint"`
+		ID        uint64 `json:"id,omitempty"`
+		Leader    uint64 `json:"leader,omitempty"`
+		Term      uint64 `json:"term,omitempty"`
+		Commit    uint64 `json:"commit,omitempty"`
+		Applied   uint64 `json:"applied,omitempty"`
+		RaftState string `json:"raft_state,omitempty"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	// Every endpoint is reported, the command failing when any of them did.
+	var firstErr error
+	results := make([]result, 0, len(g.endpoints))
+	for _, endpoint := range g.endpoints {
+		status, err := c.Status(ctx, endpoint)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			results = append(results, result{Endpoint: endpoint, Error: err.Error()})
+			continue
+		}
+
+		results = append(results, result{
+			Endpoint:  endpoint,
+			ID:        status.ID,
+			Leader:    status.Lead,
+			Term:      status.Term,
+			Commit:    status.Commit,
+			Applied:   status.Applied,
+			RaftState: status.RaftState,
+		})
+	}
+
+	switch g.output {
+	case formatJSON:
+		if err := printJSON(g.stdout, results); err != nil {
+			return err
+		}
+	default:
+		rows := make([][]string, 0, len(results))
+		for _, r := range results {
+			if r.Error != "" {
+				rows = append(rows, []string{r.Endpoint, "", "", "", "", "", "", r.Error})
+				continue
+			}
+
+			rows = append(rows, []string{
+				r.Endpoint,
+				strconv.FormatUint(r.ID, 10),
+				strconv.FormatUint(r.Leader, 10),
+				strconv.FormatUint(r.Term, 10),
+				strconv.FormatUint(r.Commit, 10),
+				strconv.FormatUint(r.Applied, 10),
+				r.RaftState,
+				"",
+			})
+		}
+		header := []string{"ENDPOINT", "ID", "LEADER", "TERM", "COMMIT", "APPLIED", "STATE", "ERROR"}
+		if err := printTable(g.stdout, header, rows); err != nil {
+			return err
+		}
+	}
+
+	return firstErr
+}
+
+func membersCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	if len(args) == 0 || args[0] == "list" {
+		members, err := c.Members(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch g.output {
+		case formatJSON:
+			return printJSON(g.stdout, members)
+		default:
+			rows := make([][]string, 0, len(members))
+			for _, m := range members {
+				rows = append(rows, []string{
+					strconv.FormatUint(m.ID, 10),
+					m.PeerAddr,
+					m.ClientURL,
+					strconv.FormatBool(m.IsLeader),
+				})
+			}
+			return printTable(g.stdout, []string{"ID", "PEER_ADDR", "CLIENT_URL", "LEADER"}, rows)
+		}
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) != 4 {
+			return usagef("members add takes an id, a peer address and a client url")
+		}
+		ID, err := parseID(args[1])
+		if err != nil {
+			return err
+		}
+
+		member := client.Member{ID: ID, PeerAddr: args[2], ClientURL: args[3]}
+		if err := c.AddMember(ctx, member); err != nil {
+			return err
+		}
+		return printDone(g, member, fmt.Sprintf("added member %d", ID))
+	case "remove":
+		if len(args) != 2 {
+			return usagef("members remove takes a single id")
+		}
+		ID, err := parseID(args[1])
+		if err != nil {
+			return err
+		}
+
+		if err := c.RemoveMember(ctx, ID); err != nil {
+			return err
+		}
+		return printDone(g, map[string]uint64{"removed": ID}, fmt.Sprintf("removed member %d", ID))
+	default:
+		return usagef("unknown members subcommand %q", args[0])
+	}
+}
+
+func snapshotCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	if len(args) != 2 || args[0] != "save" {
+		return usagef("usage: snapshot save <file>")
+	}
+	path := args[1]
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	snapshot, err := c.Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	// The snapshot is written aside and renamed once complete, so path never
+	// holds a partial one.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	size, err := io.Copy(f, snapshot)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	return printDone(g, map[string]any{"file": path, "bytes": size}, fmt.Sprintf("saved %d bytes to %s", size, path))
+}
+
+func leaderCommand(ctx context.Context, c *client.Client, g globals, args []string) error {
+	if len(args) != 2 || args[0] != "transfer" {
+		return usagef("usage: leader transfer <id>")
+	}
+	ID, err := parseID(args[1])
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	if err := c.TransferLeadership(ctx, ID); err != nil {
+		return err
+	}
+
+	return printDone(g, map[string]uint64{"leader": ID}, fmt.Sprintf("member %d is the leader", ID))
+}
+
+// printDone reports a command without a natural output, v in JSON and msg
+// otherwise.
+func printDone(g globals, v any, msg string) error {
+	switch g.output {
+	case formatJSON:
+		return printJSON(g.stdout, v)
+	case formatRaw:
+		return nil
+	default:
+		_, err := fmt.Fprintln(g.stdout, msg)
+		return err
+	}
+}
@@ -0,0 +1,184 @@
+// Command kvctl talks to a key-value store cluster over its HTTP API.
+//
+// Usage:
+//
+//	kvctl [global flags] <command> [flags] [args]
+//
+// Exit codes:
+//
+//	0  success
+//	1  unexpected error
+//	2  invalid usage
+//	3  key or member not found
+//	4  precondition failed or conflicting state
+//	5  cluster unavailable: no leader, overloaded, rate limited, unreachable
+//	   endpoints or timeout
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/pablovarg/distributed-key-value-store/client"
+)
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitNotFound
+	exitPrecondition
+	exitUnavailable
+)
+
+const usage = `Usage: kvctl [global flags] <command> [flags] [args]
+
+Commands:
+  put [-ttl seconds] [-lease id] <key> [value]   store a value, read from stdin when omitted or "-"
+  get <key>                                      print a value
+  del [-prefix] <key>                            delete a key, or every key starting with it
+  list [-limit n] [prefix]                       list the keys starting with prefix
+  scan [-limit n] <start> [end]                  list the keys in [start, end)
+  watch [-prefix] [-rev n] <key>                 stream the changes on a key until interrupted
+  status                                         print the raft status of every endpoint
+  members [list]                                 list the cluster members
+  members add <id> <peer addr> <client url>      add a member started with JOIN=true
+  members remove <id>                            remove a member
+  snapshot save <file>                           save a snapshot of the store
+  leader transfer <id>                           hand the leadership over to a member
+
+Global flags:
+`
+
+// usageError reports a malformed command line.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+type globals struct {
+	endpoints []string
+	output    format
+	timeout   time.Duration
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("kvctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	defaultEndpoints := "http://localhost:8000"
+	if env, ok := os.LookupEnv("KVCTL_ENDPOINTS"); ok {
+		defaultEndpoints = env
+	}
+
+	endpoints := fs.String("endpoints", defaultEndpoints, "comma separated API URLs of the cluster members, defaults to $KVCTL_ENDPOINTS")
+	output := fs.String("o", string(formatTable), "output format: table, json or raw")
+	token := fs.String("token", os.Getenv("KVCTL_TOKEN"), "bearer token sent once auth is enabled, defaults to $KVCTL_TOKEN")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of every command but watch")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	g := globals{
+		endpoints: strings.Split(*endpoints, ","),
+		output:    format(*output),
+		timeout:   *timeout,
+		stdin:     stdin,
+		stdout:    stdout,
+		stderr:    stderr,
+	}
+	if !g.output.valid() {
+		fmt.Fprintf(stderr, "kvctl: unknown output format %q\n", *output)
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "kvctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+
+	c, err := client.New(client.Config{Endpoints: g.endpoints, Token: *token})
+	if err != nil {
+		fmt.Fprintf(stderr, "kvctl: %s\n", err)
+		return exitUsage
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := cmd(ctx, c, g, fs.Args()[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(stderr, "kvctl: %s\n", err)
+		}
+		return exitCode(err)
+	}
+
+	return exitOK
+}
+
+func exitCode(err error) int {
+	var usageErr usageError
+	var clientErr *client.Error
+
+	switch {
+	case errors.As(err, &usageErr), errors.Is(err, flag.ErrHelp):
+		return exitUsage
+	case errors.Is(err, client.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, client.ErrPreconditionFailed):
+		return exitPrecondition
+	case errors.Is(err, client.ErrNoLeader),
+		errors.Is(err, client.ErrOverloaded),
+		errors.Is(err, client.ErrRateLimited),
+		errors.Is(err, context.DeadlineExceeded):
+		return exitUnavailable
+	case errors.As(err, &clientErr):
+		switch clientErr.StatusCode {
+		case 0, http.StatusGatewayTimeout:
+			return exitUnavailable
+		case http.StatusConflict:
+			return exitPrecondition
+		case http.StatusBadRequest, http.StatusUnprocessableEntity:
+			return exitUsage
+		}
+	}
+
+	return exitError
+}
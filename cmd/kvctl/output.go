@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+type format string
+
+const (
+	formatTable format = "table"
+	formatJSON  format = "json"
+	formatRaw   format = "raw"
+)
+
+func (f format) valid() bool {
+	return f == formatTable || f == formatJSON || f == formatRaw
+}
+
+// printJSON writes v as a single line, so streamed results stay one per line.
+func printJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// printTable writes rows aligned under header.
+func printTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// printRaw writes values untouched and separated by newlines, without a
+// trailing one so a single value is output byte for byte.
+func printRaw(w io.Writer, values ...[]byte) error {
+	for i, value := range values {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
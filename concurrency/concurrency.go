@@ -0,0 +1,170 @@
+// Package concurrency implements coordination recipes on top of leases and
+// revision ordering: every participant owns a key under a shared prefix,
+// attached to its lease, and the keys with the lowest create revisions hold
+// the resource. The create revision doubles as a fencing token since it only
+// grows between holders.
+package concurrency
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+const prefixRoot = "_concurrency/"
+
+var (
+	SessionExpiredError = errors.New("participant key vanished, its lease probably expired")
+	NotHolderError      = errors.New("key is not held by the given revision")
+)
+
+func LockPrefix(name string) string {
+	return prefixRoot + "locks/" + name + "/"
+}
+
+func SemaphorePrefix(name string) string {
+	return prefixRoot + "semaphores/" + name + "/"
+}
+
+func ElectionPrefix(name string) string {
+	return prefixRoot + "elections/" + name + "/"
+}
+
+// Participants returns the keys under prefix ordered by create revision, the
+// order in which they acquire the resource.
+func Participants(s *store.Store, prefix string) []store.Entry {
+	res := s.List(prefix)
+	slices.SortFunc(res, func(a, b store.Entry) int {
+		return cmp.Compare(a.CreateRevision, b.CreateRevision)
+	})
+
+	return res
+}
+
+// Holders returns the participants currently holding one of permits slots.
+func Holders(s *store.Store, prefix string, permits int) []store.Entry {
+	res := Participants(s, prefix)
+
+	return res[:min(permits, len(res))]
+}
+
+// Acquire registers lease as a participant under prefix and blocks until it is
+// among the first permits participants or ctx is done, in which case the
+// participant key is removed again.
+func Acquire(
+	ctx context.Context,
+	s *store.Store,
+	p internalRaft.Proposer,
+	prefix string,
+	lease int64,
+	value []byte,
+	permits int,
+) (store.Entry, error) {
+	w, err := s.Watch(prefix, true, 0)
+	if err != nil {
+		return store.Entry{}, err
+	}
+	defer func() { w.Cancel() }()
+
+	key := prefix + strconv.FormatInt(lease, 16)
+	if _, err := s.GetEntry(key); err != nil {
+		if _, err := p.Propose(ctx, internalRaft.StoreAction{
+			Action: internalRaft.Put,
+			Key:    key,
+			Value:  value,
+			Lease:  lease,
+		}); err != nil {
+			return store.Entry{}, err
+		}
+	}
+
+	for {
+		participants := Participants(s, prefix)
+		rank := slices.IndexFunc(participants, func(e store.Entry) bool {
+			return e.Key == key
+		})
+
+		switch {
+		case rank == -1:
+			return store.Entry{}, SessionExpiredError
+		case rank < permits:
+			return participants[rank], nil
+		}
+
+		select {
+		case _, ok := <-w.Events():
+			if ok {
+				continue
+			}
+
+			w, err = s.Watch(prefix, true, 0)
+			if err != nil {
+				return store.Entry{}, err
+			}
+		case <-ctx.Done():
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			Release(cleanupCtx, s, p, key, participants[rank].CreateRevision)
+			return store.Entry{}, ctx.Err()
+		}
+	}
+}
+
+// Release gives up the participant key created at revision.
+func Release(ctx context.Context, s *store.Store, p internalRaft.Proposer, key string, revision int64) error {
+	e, err := s.GetEntry(key)
+	if err != nil {
+		return err
+	}
+
+	if e.CreateRevision != revision {
+		return NotHolderError
+	}
+
+	_, err = p.Propose(ctx, internalRaft.StoreAction{
+		Action:   internalRaft.Delete,
+		Key:      key,
+		Revision: e.ModRevision,
+	})
+
+	return err
+}
+
+// Proclaim replaces the value of the participant key created at revision
+// without giving up its place.
+func Proclaim(
+	ctx context.Context,
+	s *store.Store,
+	p internalRaft.Proposer,
+	key string,
+	revision int64,
+	value []byte,
+) (store.Entry, error) {
+	e, err := s.GetEntry(key)
+	if err != nil {
+		return store.Entry{}, err
+	}
+
+	if e.CreateRevision != revision {
+		return store.Entry{}, NotHolderError
+	}
+
+	if _, err := p.Propose(ctx, internalRaft.StoreAction{
+		Action:   internalRaft.Put,
+		Key:      key,
+		Value:    value,
+		Lease:    e.Lease,
+		Revision: e.ModRevision,
+	}); err != nil {
+		return store.Entry{}, err
+	}
+
+	return s.GetEntry(key)
+}
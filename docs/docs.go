@@ -15,6 +15,884 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/alarms": {
+            "get": {
+                "description": "lists the raised alarms. A NOSPACE alarm without namespace covers the whole cluster",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/alarms/disarm": {
+            "post": {
+                "description": "disarms the NOSPACE alarm of a namespace, or of the cluster when none is given, letting writes through until a quota is hit again",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Namespace",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.NewAlarmDisarmHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/auth/disable": {
+            "post": {
+                "description": "lets every request through without a token",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/auth/enable": {
+            "post": {
+                "description": "requires a token on every request from now on. A user with the root role must hold a token beforehand",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/auth/roles": {
+            "get": {
+                "description": "lists the roles and the prefixes they grant",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/auth/roles/{name}": {
+            "get": {
+                "description": "returns the prefixes a role grants",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "role name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            },
+            "put": {
+                "description": "creates a role or replaces its permissions, each granting read, write or both on the keys under a prefix of a namespace, the root key space when omitted. An empty prefix covers every key of the namespace",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "role name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Permissions",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewRolePutHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "delete": {
+                "description": "deletes a role, taking it away from every user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "role name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/auth/status": {
+            "get": {
+                "description": "reports whether requests must carry a token",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/auth/tokens/{id}": {
+            "delete": {
+                "description": "revokes a token, requests carrying it are rejected from now on",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "token ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/auth/users": {
+            "get": {
+                "description": "lists the users and their roles",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/auth/users/{name}": {
+            "get": {
+                "description": "returns a user's roles and tokens, without their secrets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            },
+            "put": {
+                "description": "creates a user or replaces its roles. The root role grants everything, including administration",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Roles",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewUserPutHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            },
+            "delete": {
+                "description": "deletes a user along with its tokens",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/auth/users/{name}/tokens": {
+            "post": {
+                "description": "issues a token for a user. The token is only returned in this response, requests carry it as a bearer token",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Expiry",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.NewTokenCreateHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/batch": {
+            "post": {
+                "description": "applies many puts and deletes atomically as a single raft entry, all of them under the same revision",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Operations",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewBatchHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/cluster": {
+            "get": {
+                "description": "reports this node's view of the cluster: every member with its role, reachability and, when this node leads, its replication progress, along with the node's indexes, store size, uptime and version",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/concurrency/elections/{name}/campaign": {
+            "post": {
+                "description": "campaigns in an election, blocking until the given lease is the leader",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "election name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Owning lease and leader value",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewCampaignHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/concurrency/elections/{name}/leader": {
+            "get": {
+                "description": "retrieves the current leader of an election",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "election name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/concurrency/elections/{name}/observe": {
+            "get": {
+                "description": "streams every change of leader of an election as newline delimited JSON",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "election name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/concurrency/elections/{name}/proclaim": {
+            "post": {
+                "description": "updates the value announced by the current election leader",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "election name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Leader key, revision and new value",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewProclaimHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/concurrency/elections/{name}/resign": {
+            "post": {
+                "description": "gives up leadership, or a pending campaign, in an election",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "election name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Leader key and revision",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.releaseInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/concurrency/locks/{name}/acquire": {
+            "post": {
+                "description": "acquires a distributed mutex, blocking until it is held by the given lease. The returned revision is a fencing token that grows with every new holder",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "lock name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Owning lease",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewLockHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/concurrency/locks/{name}/release": {
+            "post": {
+                "description": "releases a distributed mutex held under the given fencing token",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "lock name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Held key and revision",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.releaseInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/concurrency/semaphores/{name}/acquire": {
+            "post": {
+                "description": "acquires one of the permits of a counting semaphore, blocking until the given lease holds it",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "semaphore name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Owning lease and permits",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewSemaphoreAcquireHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/concurrency/semaphores/{name}/release": {
+            "post": {
+                "description": "releases a permit of a counting semaphore",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "semaphore name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Held key and revision",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.releaseInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/leader/transfer": {
+            "post": {
+                "description": "hands the leadership over to another member and waits until it takes over",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Transferee",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewLeaderTransferHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/leases": {
+            "post": {
+                "description": "grants a lease that expires unless kept alive, deleting every key attached to it",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Lease TTL",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewLeaseGrantHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    }
+                }
+            }
+        },
+        "/leases/{id}": {
+            "get": {
+                "description": "retrieves a lease with its deadline and attached keys",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "lease ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "delete": {
+                "description": "revokes a lease, deleting every key attached to it",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "lease ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/leases/{id}/keepalive": {
+            "post": {
+                "description": "pushes a lease's deadline back by its TTL",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "lease ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/members": {
+            "get": {
+                "description": "lists the cluster members and their addresses",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "post": {
+                "description": "adds a member to the cluster through a replicated configuration change. The new node must be started with JOIN=true",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Member",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewMemberAddHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    }
+                }
+            }
+        },
+        "/members/{id}": {
+            "delete": {
+                "description": "removes a member from the cluster through a replicated configuration change",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "member ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/namespaces": {
+            "get": {
+                "description": "lists the namespaces, the size of their key spaces and their quotas",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "post": {
+                "description": "creates an empty namespace, served under /ns/{ns} with its own keys, revisions, leases and watches",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Namespace",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewNamespaceCreateHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    },
+                    "409": {
+                        "description": "Conflict"
+                    }
+                }
+            }
+        },
+        "/namespaces/{ns}": {
+            "get": {
+                "description": "returns the size, quota and revision of a namespace's key space",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "namespace",
+                        "name": "ns",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            },
+            "delete": {
+                "description": "deletes a namespace with every key and lease in it, ending its watches",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "namespace",
+                        "name": "ns",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/namespaces/{ns}/quota": {
+            "put": {
+                "description": "sets the key count and byte quotas of the cluster, or of the namespace in the path. Zero leaves a dimension unlimited. Writes exceeding a quota fail and raise a NOSPACE alarm",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Quota",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.quotaInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/quota": {
+            "get": {
+                "description": "returns the quota of the whole cluster, namespaces included, along with its usage",
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "put": {
+                "description": "sets the key count and byte quotas of the cluster, or of the namespace in the path. Zero leaves a dimension unlimited. Writes exceeding a quota fail and raise a NOSPACE alarm",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Quota",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.quotaInput"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/snapshot": {
+            "get": {
+                "description": "streams a point-in-time snapshot of the store, in the format nodes exchange when catching up",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
         "/status/": {
             "get": {
                 "description": "gets raft state",
@@ -25,40 +903,266 @@ const docTemplate = `{
                 }
             }
         },
-        "/values": {
+        "/values": {
+            "get": {
+                "description": "lists the keys starting with prefix, or within [start, end), ordered by key and read at a single revision. Without filters every key is listed",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "prefix of the keys to list",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "first key to list",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "key right after the last one to list",
+                        "name": "end",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "maximum number of keys to return",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            },
+            "post": {
+                "description": "inserts or updates a key's value",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Key / Value pair",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewPutHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    }
+                }
+            },
+            "delete": {
+                "description": "deletes every key starting with prefix, or within [start, end), as a single replicated operation. An empty end leaves the range unbounded",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "prefix of the keys to delete",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "first key to delete",
+                        "name": "start",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "key right after the last one to delete",
+                        "name": "end",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/values/_mget": {
+            "post": {
+                "description": "retrieves many keys at once, all read at the same revision",
+                "consumes": [
+                    "application/json"
+                ],
+                "parameters": [
+                    {
+                        "description": "Keys",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.NewMultiGetHandler.input"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    }
+                }
+            }
+        },
+        "/values/{key}": {
+            "get": {
+                "description": "retrieves a key's value. With Accept: application/octet-stream the raw value is returned, under the Content-Type it was written with. The ETag is the key's mod revision: If-None-Match answers 304 while it is current, and wait long-polls until the key changes past index",
+                "produces": [
+                    "application/json",
+                    "application/octet-stream"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key",
+                        "name": "query",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "how long to wait for a change, as a duration such as 30s",
+                        "name": "wait",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "mod revision to wait past, the current one by default",
+                        "name": "index",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETags the client already has",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    }
+                }
+            },
+            "put": {
+                "description": "inserts or updates a key's value with the raw request body, storing its Content-Type along with it",
+                "consumes": [
+                    "*/*"
+                ],
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "seconds until the key expires",
+                        "name": "ttl_seconds",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "lease to attach the key to",
+                        "name": "lease",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large"
+                    }
+                }
+            },
+            "delete": {
+                "description": "deletes a key, value pair from the store",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "key",
+                        "name": "query",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    }
+                }
+            }
+        },
+        "/values/{key}/decr": {
             "post": {
-                "description": "inserts or updates a key's value",
+                "description": "atomically subtracts delta, one by default, from the 64-bit integer stored in a key. Missing keys start from zero",
                 "consumes": [
                     "application/json"
                 ],
                 "parameters": [
                     {
-                        "description": "Key / Value pair",
+                        "type": "string",
+                        "description": "key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Delta",
                         "name": "input",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.NewPutHandler.input"
+                            "$ref": "#/definitions/api.counterInput"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created"
+                    "200": {
+                        "description": "OK"
                     }
                 }
             }
         },
-        "/values/{key}": {
-            "get": {
-                "description": "retrieves a key's value",
+        "/values/{key}/incr": {
+            "post": {
+                "description": "atomically adds delta, one by default, to the 64-bit integer stored in a key. Missing keys start from zero",
+                "consumes": [
+                    "application/json"
+                ],
                 "parameters": [
                     {
                         "type": "string",
                         "description": "key",
-                        "name": "query",
+                        "name": "key",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Delta",
+                        "name": "input",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/api.counterInput"
+                        }
                     }
                 ],
                 "responses": {
@@ -66,16 +1170,35 @@ const docTemplate = `{
                         "description": "OK"
                     }
                 }
-            },
-            "delete": {
-                "description": "deletes a key, value pair from the store",
+            }
+        },
+        "/watch": {
+            "get": {
+                "description": "streams changes on a key or prefix, as server-sent events when requested through the Accept header and as newline delimited JSON otherwise",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "key",
-                        "name": "query",
-                        "in": "path",
-                        "required": true
+                        "description": "key to watch",
+                        "name": "key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "prefix to watch",
+                        "name": "prefix",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "revision to resume from",
+                        "name": "start_revision",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "periodically report the current revision",
+                        "name": "progress_notify",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -87,6 +1210,175 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "api.NewAlarmDisarmHandler.input": {
+            "type": "object",
+            "properties": {
+                "namespace": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.NewBatchHandler.input": {
+            "type": "object",
+            "required": [
+                "operations"
+            ],
+            "properties": {
+                "operations": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/api.NewBatchHandler.operation"
+                    }
+                }
+            }
+        },
+        "api.NewBatchHandler.operation": {
+            "type": "object",
+            "required": [
+                "key"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string"
+                },
+                "lease": {
+                    "type": "integer"
+                },
+                "op": {
+                    "type": "string",
+                    "enum": [
+                        "put",
+                        "delete"
+                    ]
+                },
+                "revision": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "ttl_seconds": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "value": {
+                    "type": "string",
+                    "format": "base64"
+                }
+            }
+        },
+        "api.NewCampaignHandler.input": {
+            "type": "object",
+            "required": [
+                "lease"
+            ],
+            "properties": {
+                "lease": {
+                    "type": "integer"
+                },
+                "timeout_seconds": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "value": {
+                    "type": "string",
+                    "format": "base64"
+                }
+            }
+        },
+        "api.NewLeaderTransferHandler.input": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.NewLeaseGrantHandler.input": {
+            "type": "object",
+            "properties": {
+                "ttl_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.NewLockHandler.input": {
+            "type": "object",
+            "required": [
+                "lease"
+            ],
+            "properties": {
+                "lease": {
+                    "type": "integer"
+                },
+                "timeout_seconds": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "api.NewMemberAddHandler.input": {
+            "type": "object",
+            "required": [
+                "client_url",
+                "peer_addr"
+            ],
+            "properties": {
+                "client_url": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "peer_addr": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.NewMultiGetHandler.input": {
+            "type": "object",
+            "required": [
+                "keys"
+            ],
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.NewNamespaceCreateHandler.input": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.NewProclaimHandler.input": {
+            "type": "object",
+            "required": [
+                "key",
+                "revision"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string"
+                },
+                "revision": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string",
+                    "format": "base64"
+                }
+            }
+        },
         "api.NewPutHandler.input": {
             "type": "object",
             "required": [
@@ -97,11 +1389,127 @@ const docTemplate = `{
                 "key": {
                     "type": "string"
                 },
+                "lease": {
+                    "type": "integer"
+                },
+                "ttl_seconds": {
+                    "type": "integer",
+                    "minimum": 0
+                },
                 "value": {
                     "type": "string",
                     "format": "base64"
                 }
             }
+        },
+        "api.NewRolePutHandler.input": {
+            "type": "object",
+            "required": [
+                "permissions"
+            ],
+            "properties": {
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.permissionInput"
+                    }
+                }
+            }
+        },
+        "api.NewSemaphoreAcquireHandler.input": {
+            "type": "object",
+            "required": [
+                "lease"
+            ],
+            "properties": {
+                "lease": {
+                    "type": "integer"
+                },
+                "permits": {
+                    "type": "integer"
+                },
+                "timeout_seconds": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "api.NewTokenCreateHandler.input": {
+            "type": "object",
+            "properties": {
+                "ttl_seconds": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "api.NewUserPutHandler.input": {
+            "type": "object",
+            "required": [
+                "roles"
+            ],
+            "properties": {
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.counterInput": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "api.permissionInput": {
+            "type": "object",
+            "properties": {
+                "namespace": {
+                    "type": "string"
+                },
+                "prefix": {
+                    "type": "string"
+                },
+                "read": {
+                    "type": "boolean"
+                },
+                "write": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.quotaInput": {
+            "type": "object",
+            "properties": {
+                "max_bytes": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "max_keys": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "api.releaseInput": {
+            "type": "object",
+            "required": [
+                "key",
+                "revision"
+            ],
+            "properties": {
+                "key": {
+                    "type": "string"
+                },
+                "revision": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
@@ -1,3 +1,4 @@
 package main
 
 //go:generate go tool swag init --generalInfo api/server.go
+//go:generate go tool buf generate
@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,44 +18,97 @@ import (
 
 	"github.com/pablovarg/distributed-key-value-store/api"
 	"github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/ratelimit"
+	"github.com/pablovarg/distributed-key-value-store/resp"
+	"github.com/pablovarg/distributed-key-value-store/rpc"
 	"github.com/pablovarg/distributed-key-value-store/store"
+	"github.com/pablovarg/distributed-key-value-store/tracing"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.etcd.io/raft/v3/raftpb"
 )
 
 type AppConf struct {
-	Debug      bool
-	Addr       string
-	PeerAddr   string
-	ID         uint64
-	Peers      []string
-	StorageDir string
+	Debug              bool
+	Addr               string
+	GRPCAddr           string
+	RedisAddr          string
+	PeerAddr           string
+	ID                 uint64
+	Join               bool
+	Peers              []string
+	APIURL             string
+	APIPeers           []string
+	StorageDir         string
+	MaxSizePerMsg      uint64
+	MaxBatchOperations int
+	MaxBatchBytes      int
+	MaxRequestBytes    int
+	MaxValueBytes      int
+	RateLimit          ratelimit.Limit
+	RouteRateLimits    map[string]ratelimit.Limit
+	PeerSecret         string
+	MaxInflight        int
+	MaxUncommitted     uint64
+	Tracing            tracing.Config
 }
 
+// version is set at build time with -ldflags "-X main.version=...", and
+// otherwise taken from the build info.
+var version = ""
+
 func main() {
 	run(os.Stdout)
 }
 
 func run(w io.Writer) {
+	startedAt := time.Now()
 	messagesTx := make(chan raftpb.Message)
 	messagesRx := make(chan raftpb.Message)
 
 	c := ReadConf()
 	l := NewLogger(w, c.Debug)
 	s := store.NewKeyValueStore()
+	members := BuildMembers(c)
 	t := raft.NewTransport(
 		l,
 		c.PeerAddr,
-		func(u uint64) string { return c.Peers[u-1] },
+		func(u uint64) string {
+			member, _ := members.Get(u)
+			return member.PeerAddr
+		},
 		messagesRx,
 		messagesTx,
 	)
-	n := raft.NewRaftNode(l, s, messagesTx, t)
+	n := raft.NewRaftNode(l, s, members, messagesTx, t)
+
+	n.StartNode(c.ID, raft.NodeConfig{
+		MaxSizePerMsg: c.MaxSizePerMsg,
+		Join:          c.Join,
+		MaxValueBytes: c.MaxValueBytes,
+
+		MaxInflightProposals: c.MaxInflight,
+		MaxUncommittedBytes:  c.MaxUncommitted,
+	})
 
-	n.StartNode(c.ID, c.Peers)
+	prometheus.MustRegister(raft.NewCollector(n), store.NewCollector(s))
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer cancel()
 
+	shutdownTracing, err := tracing.Setup(ctx, c.Tracing)
+	if err != nil {
+		l.Error("error setting up tracing", "err", err)
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			l.Error("error flushing traces", "err", err)
+		}
+	}()
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -69,7 +125,23 @@ func run(w io.Writer) {
 		n.StepToMessages(ctx)
 	}()
 
-	srv := api.NewHTTPServer(l, c.Addr, n.RaftNode, s)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		n.ExpireLoop(ctx)
+	}()
+
+	limiter := ratelimit.NewLimiter(c.RateLimit, c.RouteRateLimits)
+	srv := api.NewHTTPServer(l, c.Addr, n.RaftNode, n, n, s, members, api.Config{
+		MaxBatchOperations: c.MaxBatchOperations,
+		MaxBatchBytes:      c.MaxBatchBytes,
+		MaxRequestBytes:    c.MaxRequestBytes,
+		Limiter:            limiter,
+		PeerSecret:         c.PeerSecret,
+		Version:            Version(),
+		StartedAt:          startedAt,
+	})
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -101,6 +173,58 @@ func run(w io.Writer) {
 		}
 	}()
 
+	grpcSrv := rpc.NewGRPCServer(l, n.RaftNode, n, n, s, members, limiter)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		lis, err := net.Listen("tcp", c.GRPCAddr)
+		if err != nil {
+			l.Error("error listening for grpc", "err", err)
+			return
+		}
+
+		l.Info("grpc server listening on address", "addr", c.GRPCAddr)
+		if err := grpcSrv.Serve(lis); err != nil {
+			l.Error("error on grpc Serve", "err", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		<-ctx.Done()
+
+		// Watch streams only end with their clients, so they are cut once
+		// the grace period is over.
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+
+		l.Info("grpc server shutting down")
+		select {
+		case <-stopped:
+		case <-time.After(10 * time.Second):
+			grpcSrv.Stop()
+		}
+	}()
+
+	if c.RedisAddr != "" {
+		redisSrv := resp.NewServer(l, c.RedisAddr, n.RaftNode, n, s, members, limiter)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			l.Info("redis server listening on address", "addr", c.RedisAddr)
+			if err := redisSrv.ListenAndServe(ctx); err != nil {
+				l.Error("error on redis ListenAndServe", "err", err)
+			}
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -111,6 +235,31 @@ func run(w io.Writer) {
 	wg.Wait()
 }
 
+// Version returns the version the binary was built with: the one set at
+// build time, the module's when installed with go install, or the VCS
+// revision of a local build.
+func Version() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return "devel"
+}
+
 func NewLogger(w io.Writer, debug bool) *slog.Logger {
 	level := slog.LevelInfo
 	if debug {
@@ -145,9 +294,20 @@ func ReadConf() AppConf {
 	c.ID = ID
 
 	ReadPeersConf(&c)
+	ReadJoinFlag(&c)
 	ReadAddr(&c)
+	ReadGRPCAddr(&c)
+	ReadRedisAddr(&c)
+	ReadAPIURLs(&c)
 	ReadPeerAddr(&c)
 	ReadDebugFlag(&c)
+	ReadMaxSizePerMsg(&c)
+	ReadBatchConf(&c)
+	ReadMaxValueBytes(&c)
+	ReadMaxRequestBytes(&c)
+	ReadRateLimits(&c)
+	ReadAdmissionConf(&c)
+	ReadTracingConf(&c)
 
 	return c
 }
@@ -162,6 +322,16 @@ func ReadDebugFlag(c *AppConf) {
 	c.Debug = true
 }
 
+func ReadJoinFlag(c *AppConf) {
+	join := os.Getenv("JOIN")
+
+	if strings.TrimSpace(strings.ToLower(join)) != "true" {
+		return
+	}
+
+	c.Join = true
+}
+
 func ReadPeersConf(c *AppConf) {
 	envPeers, ok := os.LookupEnv("PEERS")
 	if !ok {
@@ -171,6 +341,50 @@ func ReadPeersConf(c *AppConf) {
 	c.Peers = strings.Split(envPeers, ",")
 }
 
+// BuildMembers registers this node and its peers. PEERS and API_PEERS list
+// every other node in ID order, skipping this node's own ID.
+func BuildMembers(c AppConf) *raft.Members {
+	members := raft.NewMembers()
+	members.Set(raft.Member{
+		ID:        c.ID,
+		PeerAddr:  c.PeerAddr,
+		ClientURL: c.APIURL,
+	})
+
+	for i, peer := range c.Peers {
+		ID := uint64(i + 1)
+		if ID >= c.ID {
+			ID++
+		}
+
+		member := raft.Member{
+			ID:       ID,
+			PeerAddr: peer,
+		}
+		if i < len(c.APIPeers) {
+			member.ClientURL = c.APIPeers[i]
+		}
+
+		members.Set(member)
+	}
+
+	return members
+}
+
+func ReadAPIURLs(c *AppConf) {
+	c.APIURL = "http://localhost" + c.Addr
+	if url, ok := os.LookupEnv("API_URL"); ok {
+		c.APIURL = url
+	}
+
+	envPeers, ok := os.LookupEnv("API_PEERS")
+	if !ok {
+		return
+	}
+
+	c.APIPeers = strings.Split(envPeers, ",")
+}
+
 func ReadAddr(c *AppConf) {
 	addr, ok := os.LookupEnv("API_ADDRESS")
 	if !ok {
@@ -181,6 +395,22 @@ func ReadAddr(c *AppConf) {
 	c.Addr = addr
 }
 
+func ReadGRPCAddr(c *AppConf) {
+	addr, ok := os.LookupEnv("GRPC_ADDRESS")
+	if !ok {
+		c.GRPCAddr = ":8002"
+		return
+	}
+
+	c.GRPCAddr = addr
+}
+
+// ReadRedisAddr reads the address of the optional Redis protocol listener,
+// disabled unless REDIS_ADDRESS is set.
+func ReadRedisAddr(c *AppConf) {
+	c.RedisAddr = os.Getenv("REDIS_ADDRESS")
+}
+
 func ReadPeerAddr(c *AppConf) {
 	addr, ok := os.LookupEnv("PEER_ADDRESS")
 	if !ok {
@@ -190,3 +420,147 @@ func ReadPeerAddr(c *AppConf) {
 
 	c.PeerAddr = addr
 }
+
+func ReadMaxSizePerMsg(c *AppConf) {
+	c.MaxSizePerMsg = 4096
+
+	envSize, ok := os.LookupEnv("RAFT_MAX_SIZE_PER_MSG")
+	if !ok {
+		return
+	}
+
+	size, err := strconv.ParseUint(envSize, 10, 64)
+	if err != nil {
+		panic("env RAFT_MAX_SIZE_PER_MSG is not a uint64")
+	}
+	c.MaxSizePerMsg = size
+}
+
+// ReadBatchConf reads the batch limits. A batch is a single raft entry, so it
+// may not exceed MaxSizePerMsg, read beforehand.
+func ReadBatchConf(c *AppConf) {
+	c.MaxBatchOperations = 128
+	c.MaxBatchBytes = int(c.MaxSizePerMsg)
+
+	if envOps, ok := os.LookupEnv("BATCH_MAX_OPERATIONS"); ok {
+		ops, err := strconv.Atoi(envOps)
+		if err != nil || ops <= 0 {
+			panic("env BATCH_MAX_OPERATIONS is not a positive int")
+		}
+		c.MaxBatchOperations = ops
+	}
+
+	if envBytes, ok := os.LookupEnv("BATCH_MAX_BYTES"); ok {
+		size, err := strconv.Atoi(envBytes)
+		if err != nil || size <= 0 {
+			panic("env BATCH_MAX_BYTES is not a positive int")
+		}
+		c.MaxBatchBytes = min(size, int(c.MaxSizePerMsg))
+	}
+}
+
+// ReadMaxValueBytes reads the hard limit on the size of stored values, 16 MiB
+// by default.
+func ReadMaxValueBytes(c *AppConf) {
+	c.MaxValueBytes = 16 << 20
+
+	envBytes, ok := os.LookupEnv("MAX_VALUE_BYTES")
+	if !ok {
+		return
+	}
+
+	size, err := strconv.Atoi(envBytes)
+	if err != nil || size <= 0 {
+		panic("env MAX_VALUE_BYTES is not a positive int")
+	}
+	c.MaxValueBytes = size
+}
+
+// ReadMaxRequestBytes reads the limit on request bodies, which defaults to
+// the base64 encoded maximum value size read beforehand, with room for the
+// rest of a JSON put.
+func ReadMaxRequestBytes(c *AppConf) {
+	c.MaxRequestBytes = base64.StdEncoding.EncodedLen(c.MaxValueBytes) + 64<<10
+
+	envBytes, ok := os.LookupEnv("MAX_REQUEST_BYTES")
+	if !ok {
+		return
+	}
+
+	size, err := strconv.Atoi(envBytes)
+	if err != nil || size <= 0 {
+		panic("env MAX_REQUEST_BYTES is not a positive int")
+	}
+	c.MaxRequestBytes = size
+}
+
+// ReadRateLimits reads the per client rate limits, "rate:burst" or "off",
+// and the secret marking the requests members forward to the leader.
+// Requests are not limited by default.
+func ReadRateLimits(c *AppConf) {
+	if envLimit, ok := os.LookupEnv("RATE_LIMIT"); ok {
+		rl, err := ratelimit.Parse(envLimit)
+		if err != nil {
+			panic("env RATE_LIMIT: " + err.Error())
+		}
+		c.RateLimit = rl
+	}
+
+	routes, err := ratelimit.ParseRoutes(os.Getenv("RATE_LIMIT_ROUTES"))
+	if err != nil {
+		panic("env RATE_LIMIT_ROUTES: " + err.Error())
+	}
+	c.RouteRateLimits = routes
+	c.PeerSecret = os.Getenv("PEER_SECRET")
+}
+
+// ReadAdmissionConf reads the limits past which proposals are refused: 1024
+// in flight by default, and an unbounded uncommitted log.
+func ReadAdmissionConf(c *AppConf) {
+	c.MaxInflight = 1024
+
+	if envInflight, ok := os.LookupEnv("MAX_INFLIGHT_PROPOSALS"); ok {
+		n, err := strconv.Atoi(envInflight)
+		if err != nil || n < 0 {
+			panic("env MAX_INFLIGHT_PROPOSALS is not a non-negative int")
+		}
+		c.MaxInflight = n
+	}
+
+	if envBytes, ok := os.LookupEnv("MAX_UNCOMMITTED_BYTES"); ok {
+		size, err := strconv.ParseUint(envBytes, 10, 64)
+		if err != nil {
+			panic("env MAX_UNCOMMITTED_BYTES is not a uint64")
+		}
+		c.MaxUncommitted = size
+	}
+}
+
+// ReadTracingConf reads where spans are exported: nowhere by default, to an
+// OTLP collector, to stdout, or appended to TRACING_FILE.
+func ReadTracingConf(c *AppConf) {
+	c.Tracing = tracing.Config{
+		Exporter:    os.Getenv("TRACING_EXPORTER"),
+		File:        os.Getenv("TRACING_FILE"),
+		SampleRatio: 1,
+		NodeID:      c.ID,
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	case tracing.ExporterFile:
+		if c.Tracing.File == "" {
+			panic("env TRACING_FILE is required by the file exporter")
+		}
+	default:
+		panic("env TRACING_EXPORTER is not one of otlp, stdout or file")
+	}
+
+	if envRatio, ok := os.LookupEnv("TRACING_SAMPLE_RATIO"); ok {
+		ratio, err := strconv.ParseFloat(envRatio, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			panic("env TRACING_SAMPLE_RATIO is not a number between 0 and 1")
+		}
+		c.Tracing.SampleRatio = ratio
+	}
+}
@@ -4,18 +4,90 @@ import (
 	"bytes"
 	"encoding/gob"
 	"log/slog"
+
+	"github.com/pablovarg/distributed-key-value-store/store"
 )
 
 const (
 	Put = iota
 	Get
 	Delete
+	Expire
+	LeaseGrant
+	LeaseKeepAlive
+	LeaseRevoke
+	LeaseExpire
+	Increment
+	Batch
+	DeleteRange
+	Range
+	Chunk
+	AuthEnable
+	AuthDisable
+	UserPut
+	UserDelete
+	RolePut
+	RoleDelete
+	TokenAdd
+	TokenRevoke
+	NamespaceCreate
+	NamespaceDelete
+	QuotaSet
+	AlarmDisarm
+	ChunkAbort
 )
 
 type StoreAction struct {
+	// ID identifies the proposal so the proposer can wait for its result.
+	ID     uint64
 	Action int
-	Key    string
-	Value  []byte
+	// Namespace names the key space the action applies to, the root one
+	// when empty.
+	Namespace string
+	Key       string
+	Value     []byte
+	// RangeEnd bounds a DeleteRange or Range starting at Key, empty meaning
+	// unbounded.
+	RangeEnd string
+	// ExpiresAt is the key's deadline in unix nanoseconds, computed once by
+	// the proposer so every replica stores the same one.
+	ExpiresAt int64
+	// Revision guards Expire so it only removes the version that expired,
+	// and makes Put and Delete conditional on the key's mod revision.
+	Revision int64
+	// Existence makes a Put only create or only update its key.
+	Existence store.Existence
+	// ContentType is stored along a Put's value.
+	ContentType string
+	// Upload names the value a Chunk is part of. A Put with an Upload takes
+	// its value from the chunks, whose number is given in Chunks, and a
+	// ChunkAbort drops them.
+	Upload uint64
+	// Chunks is the index of a Chunk within its upload, or the number of
+	// chunks of a Put's upload.
+	Chunks int
+	Lease  int64
+	TTL    int64
+	Delta  int64
+	// Actions holds the puts and deletes of a Batch, applied atomically. A
+	// Get in a Batch only checks its Revision and Existence preconditions,
+	// while a Range or DeleteRange reads the keys as left by the actions
+	// before it.
+	Actions []StoreAction
+	// User, Role and Token carry the records written by the auth actions.
+	// Deletes and revocations name their record in Key instead.
+	User  *store.User
+	Role  *store.Role
+	Token *store.Token
+	// Quota is set by QuotaSet on Namespace, the cluster's when empty.
+	Quota *store.Quota
+	// Caller is the user a LeaseGrant is owned by, and whose permissions a
+	// LeaseKeepAlive or LeaseRevoke is checked against as it applies. It is
+	// empty when auth is off.
+	Caller string
+	// Trace is the W3C trace context of the proposal, so every replica
+	// applying the entry links its span back to it.
+	Trace map[string]string
 }
 
 func EncodeAction(l *slog.Logger, a StoreAction) ([]byte, error) {
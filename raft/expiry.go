@@ -0,0 +1,70 @@
+package raft
+
+import "time"
+
+// expiryID identifies an expiry by what it removes: a key at a revision or a
+// lease at a deadline.
+type expiryID struct {
+	namespace string
+	key       string
+	lease     int64
+	version   int64
+}
+
+func expiryOf(action StoreAction) expiryID {
+	if action.Action == LeaseExpire {
+		return expiryID{namespace: action.Namespace, lease: action.Lease, version: action.ExpiresAt}
+	}
+
+	return expiryID{namespace: action.Namespace, key: action.Key, version: action.Revision}
+}
+
+// expiryTracker remembers the expiries proposed by the leader until they
+// apply, so each one is proposed once instead of on every tick. Only
+// ExpireLoop touches it.
+type expiryTracker struct {
+	// proposed holds when each expiry in flight may be proposed again.
+	proposed map[expiryID]time.Time
+	// due holds the expiries seen during the current tick.
+	due map[expiryID]bool
+}
+
+func newExpiryTracker() *expiryTracker {
+	return &expiryTracker{
+		proposed: make(map[expiryID]time.Time),
+		due:      make(map[expiryID]bool),
+	}
+}
+
+// propose reports whether id should be proposed, marking it in flight.
+func (t *expiryTracker) propose(id expiryID, now time.Time) bool {
+	t.due[id] = true
+	if retry, ok := t.proposed[id]; ok && now.Before(retry) {
+		return false
+	}
+	t.proposed[id] = now.Add(expireRetry)
+
+	return true
+}
+
+// failed lets id be proposed again on the next tick.
+func (t *expiryTracker) failed(id expiryID) {
+	delete(t.proposed, id)
+}
+
+// sweep forgets the expiries that were not due during the tick, they were
+// applied.
+func (t *expiryTracker) sweep() {
+	for id := range t.proposed {
+		if !t.due[id] {
+			delete(t.proposed, id)
+		}
+	}
+	clear(t.due)
+}
+
+// reset forgets every expiry in flight, once no longer leading.
+func (t *expiryTracker) reset() {
+	clear(t.proposed)
+	clear(t.due)
+}
@@ -0,0 +1,116 @@
+package raft
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/gob"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+type Member struct {
+	ID uint64
+	// PeerAddr is where the member's raft transport listens.
+	PeerAddr string
+	// ClientURL is the base URL of the member's HTTP API.
+	ClientURL string
+}
+
+// Members is the registry of cluster members and their addresses, shared by
+// the raft transport and the API.
+type Members struct {
+	mu      sync.RWMutex
+	members map[uint64]Member
+}
+
+func NewMembers() *Members {
+	return &Members{
+		members: make(map[uint64]Member),
+	}
+}
+
+func (m *Members) Set(member Member) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.members[member.ID] = member
+}
+
+func (m *Members) Remove(ID uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.members, ID)
+}
+
+func (m *Members) Get(ID uint64) (Member, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	member, ok := m.members[ID]
+	return member, ok
+}
+
+// List returns every member ordered by ID.
+func (m *Members) List() []Member {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]Member, 0, len(m.members))
+	for _, member := range m.members {
+		res = append(res, member)
+	}
+	slices.SortFunc(res, func(a, b Member) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return res
+}
+
+// Cluster changes the membership and leadership of the cluster.
+type Cluster interface {
+	// AddMember proposes adding member and waits until the change is applied
+	// on this node.
+	AddMember(ctx context.Context, member Member) error
+	// RemoveMember proposes removing the member with ID and waits until the
+	// change is applied on this node.
+	RemoveMember(ctx context.Context, ID uint64) error
+	// TransferLeadership hands the leadership over to the member with ID and
+	// waits until it takes over.
+	TransferLeadership(ctx context.Context, ID uint64) error
+	// SnapshotIndex returns the log index of the node's latest snapshot.
+	SnapshotIndex() uint64
+	// Reachable reports whether the node exchanged messages with the member
+	// with ID recently, and whether it ever did.
+	Reachable(ID uint64) (reachable, known bool)
+}
+
+var (
+	MemberExistsError   = errors.New("member already exists")
+	MemberNotFoundError = errors.New("member not found")
+)
+
+// EncodeMember serializes member into the context of the configuration change
+// adding it, so every node learns its addresses when applying the change.
+func EncodeMember(l *slog.Logger, member Member) ([]byte, error) {
+	b := new(bytes.Buffer)
+	if err := gob.NewEncoder(b).Encode(member); err != nil {
+		l.Error("error encoding member", "err", err)
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func DecodeMember(l *slog.Logger, data []byte) (Member, error) {
+	var res Member
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&res); err != nil {
+		l.Error("error decoding member", "err", err)
+		return Member{}, err
+	}
+
+	return res, nil
+}
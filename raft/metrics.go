@@ -0,0 +1,90 @@
+package raft
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	proposalDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "kv_raft_proposal_duration_seconds",
+		Help:    "Time from proposing an entry to applying it on the proposing node.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+	leaderChanges = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "kv_raft_leader_changes_total",
+		Help: "Leader changes seen by this node.",
+	})
+	transportSentBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kv_transport_sent_bytes_total",
+		Help: "Bytes of raft messages sent to each peer.",
+	}, []string{"peer"})
+	transportReceivedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kv_transport_received_bytes_total",
+		Help: "Bytes of raft messages received from each peer.",
+	}, []string{"peer"})
+	transportErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kv_transport_errors_total",
+		Help: "Raft messages that could not be sent to, or read from, each peer.",
+	}, []string{"peer"})
+)
+
+func peerLabel(ID uint64) string {
+	return strconv.FormatUint(ID, 10)
+}
+
+var (
+	termDesc = prometheus.NewDesc(
+		"kv_raft_term", "Current raft term of this node.", nil, nil)
+	commitIndexDesc = prometheus.NewDesc(
+		"kv_raft_commit_index", "Highest log index known to be committed.", nil, nil)
+	appliedIndexDesc = prometheus.NewDesc(
+		"kv_raft_applied_index", "Highest log index applied to the store.", nil, nil)
+	isLeaderDesc = prometheus.NewDesc(
+		"kv_raft_is_leader", "1 when this node is the leader, 0 otherwise.", nil, nil)
+	hasLeaderDesc = prometheus.NewDesc(
+		"kv_raft_has_leader", "1 when this node knows of a leader, 0 otherwise.", nil, nil)
+	pendingProposalsDesc = prometheus.NewDesc(
+		"kv_raft_pending_proposals", "Proposals of this node waiting to be applied.", nil, nil)
+)
+
+// collector reads the node's raft status when scraped.
+type collector struct {
+	n RaftNode
+}
+
+// NewCollector exposes the term, indexes, leadership and pending proposals
+// of the node.
+func NewCollector(n RaftNode) prometheus.Collector {
+	return collector{n: n}
+}
+
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- termDesc
+	ch <- commitIndexDesc
+	ch <- appliedIndexDesc
+	ch <- isLeaderDesc
+	ch <- hasLeaderDesc
+	ch <- pendingProposalsDesc
+}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	status := c.n.RaftNode.Status()
+
+	ch <- prometheus.MustNewConstMetric(termDesc, prometheus.GaugeValue, float64(status.Term))
+	ch <- prometheus.MustNewConstMetric(commitIndexDesc, prometheus.GaugeValue, float64(status.Commit))
+	ch <- prometheus.MustNewConstMetric(appliedIndexDesc, prometheus.GaugeValue, float64(status.Applied))
+	ch <- prometheus.MustNewConstMetric(isLeaderDesc, prometheus.GaugeValue, boolValue(status.ID == status.Lead))
+	ch <- prometheus.MustNewConstMetric(hasLeaderDesc, prometheus.GaugeValue, boolValue(status.Lead != 0))
+	ch <- prometheus.MustNewConstMetric(pendingProposalsDesc, prometheus.GaugeValue, float64(c.n.waiter.len()))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
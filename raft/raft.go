@@ -2,6 +2,8 @@ package raft
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"slices"
 	"time"
@@ -9,63 +11,131 @@ import (
 	"github.com/pablovarg/distributed-key-value-store/store"
 	"go.etcd.io/raft/v3"
 	"go.etcd.io/raft/v3/raftpb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	tickInterval = 200 * time.Millisecond
+	electionTick = 10
+	// electionTimeout is how long followers wait for the leader before
+	// campaigning, past which a silent member is deemed unreachable.
+	electionTimeout = electionTick * tickInterval
+
+	snapshotInterval       = 10000
+	snapshotCatchUpEntries = 5000
+	expireInterval         = 500 * time.Millisecond
+	// expireRetry is how long a proposed expiry is waited for before being
+	// proposed again, in case a change of leader dropped its entry.
+	expireRetry = 5 * time.Second
+	// abortTimeout bounds proposing the abort of an upload whose put failed.
+	abortTimeout = time.Second
+	// chunkOverhead is left out of MaxSizePerMsg for the rest of a chunk's
+	// entry, so each one fits in an append message.
+	chunkOverhead = 512
+	minChunkSize  = 1024
+)
+
+var (
+	ValueTooLargeError = errors.New("value exceeds the maximum size")
+	// OverloadedError refuses proposals while too many are in flight or the
+	// uncommitted log is full, so latency stays bounded under load.
+	OverloadedError = errors.New("too many proposals in flight")
+)
+
+// NodeConfig holds the tunables of the underlying raft node.
+type NodeConfig struct {
+	// MaxSizePerMsg caps the bytes of entries sent in a single append
+	// message. A single entry larger than it is still sent on its own.
+	MaxSizePerMsg uint64
+	// Join starts the node without bootstrapping a cluster, waiting for an
+	// existing one to add it as a member.
+	Join bool
+	// MaxValueBytes rejects puts of larger values before proposing them.
+	MaxValueBytes int
+	// MaxInflightProposals refuses new proposals while as many wait to be
+	// applied, zero leaving them unbounded.
+	MaxInflightProposals int
+	// MaxUncommittedBytes makes the leader drop proposals while the entries
+	// it has not committed yet exceed it, zero leaving them unbounded.
+	MaxUncommittedBytes uint64
+}
+
 type RaftNode struct {
 	ticker        time.Ticker
 	logger        *slog.Logger
 	RaftNode      raft.Node
 	storage       *raft.MemoryStorage
-	keyValueStore store.Store
-	peers         []string
+	keyValueStore *store.Store
+	members       *Members
 	messagesRx    <-chan raftpb.Message
 	transport     Transporter
+	state         *applyState
+	waiter        *waiter
+	chunkSize     int
+	maxValueBytes int
+	maxInflight   int
+}
+
+// applyState is only touched from Loop.
+type applyState struct {
+	confState     raftpb.ConfState
+	appliedIndex  uint64
+	snapshotIndex uint64
+	// lead is the leader last reported by the node, to count changes.
+	lead uint64
 }
 
 func NewRaftNode(
 	l *slog.Logger,
-	keyValueStore store.Store,
+	keyValueStore *store.Store,
+	members *Members,
 	messagesRx <-chan raftpb.Message,
 	transport Transporter,
 ) RaftNode {
 	return RaftNode{
 		logger:        l,
-		ticker:        *time.NewTicker(200 * time.Millisecond),
+		ticker:        *time.NewTicker(tickInterval),
 		storage:       raft.NewMemoryStorage(),
 		keyValueStore: keyValueStore,
+		members:       members,
 		messagesRx:    messagesRx,
 		transport:     transport,
+		state:         &applyState{},
+		waiter:        newWaiter(),
 	}
 }
 
-func (n *RaftNode) StartNode(ID uint64, peers []string) {
+// StartNode bootstraps the raft node with every member currently registered,
+// or starts it empty when joining an existing cluster.
+func (n *RaftNode) StartNode(ID uint64, conf NodeConfig) {
 	c := &raft.Config{
 		ID:              ID,
-		ElectionTick:    10,
+		ElectionTick:    electionTick,
 		HeartbeatTick:   1,
 		Storage:         n.storage,
-		MaxSizePerMsg:   4096,
+		MaxSizePerMsg:   conf.MaxSizePerMsg,
 		MaxInflightMsgs: 256,
-	}
 
-	p := []raft.Peer{{ID: ID}}
-	for i := range peers {
-		offset := uint64(1)
-		if uint64(i+1) >= ID {
-			offset = ID
-		}
-		p = append(p, raft.Peer{ID: uint64(i) + offset})
+		MaxUncommittedEntriesSize: conf.MaxUncommittedBytes,
 	}
 
-	n.peers = make([]string, 0, len(peers)+1)
-	for i, peer := range peers {
-		if uint64(i+1) == ID {
-			n.peers = append(n.peers, "")
-		}
-		n.peers = append(n.peers, peer)
+	members := n.members.List()
+	p := make([]raft.Peer, 0, len(members))
+	for member := range slices.Values(members) {
+		p = append(p, raft.Peer{ID: member.ID})
 	}
 
-	n.logger.Info("raft: StartNode", "peers", peers)
+	n.waiter.nodeID = ID
+	n.chunkSize = max(int(conf.MaxSizePerMsg)-chunkOverhead, minChunkSize)
+	n.maxValueBytes = conf.MaxValueBytes
+	n.maxInflight = conf.MaxInflightProposals
+	n.logger.Info("raft: StartNode", "members", members, "join", conf.Join)
+
+	if conf.Join {
+		n.RaftNode = raft.RestartNode(c)
+		return
+	}
 
 	n.RaftNode = raft.StartNode(c, p)
 }
@@ -93,8 +163,15 @@ func (n RaftNode) Loop(ctx context.Context) {
 		case rd := <-n.RaftNode.Ready():
 			n.logger.Debug("node ready", "message", rd)
 
+			if rd.SoftState != nil && rd.SoftState.Lead != n.state.lead {
+				n.state.lead = rd.SoftState.Lead
+				if n.state.lead != raft.None {
+					leaderChanges.Inc()
+				}
+			}
 			n.saveState(rd)
 			n.handleCommittedEntries(rd)
+			n.maybeSnapshot()
 			n.sendMessages(rd.Messages)
 
 			n.RaftNode.Advance()
@@ -105,15 +182,62 @@ func (n RaftNode) Loop(ctx context.Context) {
 }
 
 func (n RaftNode) saveState(rd raft.Ready) {
-	n.storage.Append(rd.Entries)
+	if !raft.IsEmptySnap(rd.Snapshot) {
+		n.applySnapshot(rd.Snapshot)
+	}
 
 	if !raft.IsEmptyHardState(rd.HardState) {
 		n.storage.SetHardState(rd.HardState)
 	}
 
-	if !raft.IsEmptySnap(rd.Snapshot) {
-		n.storage.ApplySnapshot(rd.Snapshot)
+	n.storage.Append(rd.Entries)
+}
+
+func (n RaftNode) applySnapshot(snap raftpb.Snapshot) {
+	if snap.Metadata.Index <= n.state.appliedIndex {
+		return
 	}
+
+	if err := n.storage.ApplySnapshot(snap); err != nil {
+		n.logger.Error("error applying raft snapshot", "err", err)
+		return
+	}
+
+	if err := n.keyValueStore.Restore(snap.Data); err != nil {
+		n.logger.Error("error restoring store from snapshot", "err", err)
+		return
+	}
+
+	n.state.confState = snap.Metadata.ConfState
+	n.state.appliedIndex = snap.Metadata.Index
+	n.state.snapshotIndex = snap.Metadata.Index
+	n.logger.Info("restored store from snapshot", "index", snap.Metadata.Index)
+}
+
+func (n RaftNode) maybeSnapshot() {
+	if n.state.appliedIndex-n.state.snapshotIndex < snapshotInterval {
+		return
+	}
+
+	data, err := n.keyValueStore.Snapshot()
+	if err != nil {
+		n.logger.Error("error taking store snapshot", "err", err)
+		return
+	}
+
+	if _, err := n.storage.CreateSnapshot(n.state.appliedIndex, &n.state.confState, data); err != nil {
+		n.logger.Error("error creating raft snapshot", "err", err)
+		return
+	}
+	n.state.snapshotIndex = n.state.appliedIndex
+
+	if n.state.appliedIndex > snapshotCatchUpEntries {
+		if err := n.storage.Compact(n.state.appliedIndex - snapshotCatchUpEntries); err != nil {
+			n.logger.Error("error compacting raft log", "err", err)
+		}
+	}
+
+	n.logger.Info("created raft snapshot", "index", n.state.snapshotIndex)
 }
 
 func (n RaftNode) handleCommittedEntries(rd raft.Ready) {
@@ -122,12 +246,23 @@ func (n RaftNode) handleCommittedEntries(rd raft.Ready) {
 	}
 
 	for entry := range slices.Values(rd.CommittedEntries) {
+		if entry.Index <= n.state.appliedIndex {
+			continue
+		}
+		n.state.appliedIndex = entry.Index
+
 		switch entry.Type {
 		case raftpb.EntryConfChange:
 			n.logger.Debug("raft configuration change", "entry", entry)
 			var cc raftpb.ConfChange
-			cc.Unmarshal(entry.Data)
-			n.RaftNode.ApplyConfChange(cc)
+			if err := cc.Unmarshal(entry.Data); err != nil {
+				n.logger.Error("committed unreadable configuration change, ignoring", "data", entry.Data)
+				continue
+			}
+
+			n.state.confState = *n.RaftNode.ApplyConfChange(cc)
+			n.applyConfChange(cc)
+			n.waiter.trigger(cc.ID, ActionResult{})
 		case raftpb.EntryNormal:
 			if entry.Data == nil {
 				break
@@ -139,19 +274,555 @@ func (n RaftNode) handleCommittedEntries(rd raft.Ready) {
 				continue
 			}
 
-			n.applyAction(action)
+			res := n.applyTraced(entry, action)
+			n.waiter.trigger(action.ID, res)
+			if action.Action == Chunk {
+				n.logger.Debug("applying committed chunk", "upload", action.Upload, "index", action.Chunks)
+				continue
+			}
 			n.logger.Info("applying committed entry", "action", action)
 		}
 	}
 }
 
-func (n RaftNode) applyAction(action StoreAction) {
+// applyConfChange keeps the members registry in line with the committed
+// configuration. The changes bootstrapping the cluster carry no context, their
+// members are registered from the configuration.
+func (n RaftNode) applyConfChange(cc raftpb.ConfChange) {
+	switch cc.Type {
+	case raftpb.ConfChangeAddNode, raftpb.ConfChangeAddLearnerNode:
+		if len(cc.Context) == 0 {
+			return
+		}
+
+		member, err := DecodeMember(n.logger, cc.Context)
+		if err != nil {
+			return
+		}
+		n.members.Set(member)
+		n.logger.Info("member added", "member", member)
+	case raftpb.ConfChangeRemoveNode:
+		n.members.Remove(cc.NodeID)
+		n.logger.Info("member removed", "ID", cc.NodeID)
+	}
+}
+
+// applyTraced applies an entry within a span linked to its proposal, on
+// every replica. Entries proposed without a trace are applied untraced.
+func (n RaftNode) applyTraced(entry raftpb.Entry, action StoreAction) ActionResult {
+	link, ok := traceLink(action.Trace)
+	if !ok {
+		return n.applyAction(entry.Index, action)
+	}
+
+	_, span := tracer.Start(context.Background(), "raft.apply",
+		trace.WithLinks(link),
+		trace.WithAttributes(
+			attribute.Int64("kv.raft.index", int64(entry.Index)),
+			attribute.Int("kv.action", action.Action),
+			attribute.String("kv.namespace", action.Namespace),
+		),
+	)
+	defer span.End()
+
+	res := n.applyAction(entry.Index, action)
+	if res.Err != nil {
+		recordError(span, res.Err)
+	}
+
+	return res
+}
+
+// applyAction applies an action committed at index to the store.
+func (n RaftNode) applyAction(index uint64, action StoreAction) ActionResult {
+	var res ActionResult
+
+	kv := n.keyValueStore
+	if action.Namespace != "" {
+		ns, err := n.keyValueStore.Namespace(action.Namespace)
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		kv = ns
+	}
+
 	switch action.Action {
+	case Chunk:
+		n.keyValueStore.AppendChunk(action.Upload, index, action.Chunks, action.Value)
+	case ChunkAbort:
+		n.keyValueStore.AbortUpload(action.Upload)
 	case Put:
-		n.keyValueStore.Put(action.Key, action.Value)
+		if action.Upload != 0 {
+			value, err := n.keyValueStore.TakeChunks(action.Upload, action.Chunks)
+			if err != nil {
+				res.Err = err
+				break
+			}
+			action.Value = value
+		}
+
+		if res.Err = n.keyValueStore.AdmitWrite(action.Namespace, kv.Growth(action.Key, action.Value)); res.Err != nil {
+			break
+		}
+
+		e, err := kv.Put(action.Key, action.Value, store.PutOptions{
+			ExpiresAt:   action.ExpiresAt,
+			Lease:       action.Lease,
+			ContentType: action.ContentType,
+			ModRevision: action.Revision,
+			Existence:   action.Existence,
+		})
+		res.Revision, res.Err = e.ModRevision, err
 	case Delete:
-		n.keyValueStore.Delete(action.Key)
+		res.Err = kv.Delete(action.Key, store.DeleteOptions{
+			ModRevision: action.Revision,
+		})
+	case DeleteRange:
+		res.Deleted = kv.DeleteRange(action.Key, action.RangeEnd)
+	case Expire:
+		res.Err = kv.Expire(action.Key, action.Revision)
+	case Increment:
+		if res.Err = n.keyValueStore.AdmitWrite(action.Namespace, kv.Growth(action.Key, nil)); res.Err != nil {
+			break
+		}
+
+		e, counter, err := kv.Increment(action.Key, action.Delta)
+		res.Revision, res.Counter, res.Err = e.ModRevision, counter, err
+	case Batch:
+		ops := make([]store.BatchOp, 0, len(action.Actions))
+		for a := range slices.Values(action.Actions) {
+			ops = append(ops, store.BatchOp{
+				Delete:   a.Action == Delete || a.Action == DeleteRange,
+				Check:    a.Action == Get,
+				Range:    a.Action == Range || a.Action == DeleteRange,
+				RangeEnd: a.RangeEnd,
+				Key:      a.Key,
+				Value:    a.Value,
+				Options: store.PutOptions{
+					ExpiresAt:   a.ExpiresAt,
+					Lease:       a.Lease,
+					ContentType: a.ContentType,
+					ModRevision: a.Revision,
+					Existence:   a.Existence,
+				},
+			})
+		}
+		if growth, puts := batchGrowth(kv, action.Actions); puts {
+			if res.Err = n.keyValueStore.AdmitWrite(action.Namespace, growth); res.Err != nil {
+				break
+			}
+		}
+
+		res.Batch, res.Err = kv.Batch(ops)
+	case LeaseGrant:
+		lease := kv.GrantLease(action.TTL, action.ExpiresAt, action.Caller)
+		res.Lease = lease.ID
+	case LeaseKeepAlive:
+		if res.Err = n.authorizeLease(kv, action, false); res.Err != nil {
+			break
+		}
+		lease, err := kv.KeepAliveLease(action.Lease, action.ExpiresAt)
+		res.Lease, res.Err = lease.ID, err
+	case LeaseRevoke:
+		res.Lease = action.Lease
+		if res.Err = n.authorizeLease(kv, action, true); res.Err != nil {
+			break
+		}
+		res.Deleted, res.Err = kv.RevokeLease(action.Lease)
+	case LeaseExpire:
+		res.Lease = action.Lease
+		res.Deleted, res.Err = kv.ExpireLease(action.Lease, action.ExpiresAt)
+	case AuthEnable, AuthDisable:
+		res.Err = kv.SetAuthEnabled(action.Action == AuthEnable)
+	case UserPut:
+		res.Err = kv.PutUser(*action.User)
+	case UserDelete:
+		res.Err = kv.DeleteUser(action.Key)
+	case RolePut:
+		res.Err = kv.PutRole(*action.Role)
+	case RoleDelete:
+		res.Err = kv.DeleteRole(action.Key)
+	case TokenAdd:
+		res.Err = kv.AddToken(*action.Token)
+	case TokenRevoke:
+		res.Err = kv.RevokeToken(action.Key)
+	case QuotaSet:
+		res.Err = n.keyValueStore.SetQuota(action.Namespace, *action.Quota)
+	case AlarmDisarm:
+		res.Err = n.keyValueStore.DisarmAlarm(action.Namespace)
+	case NamespaceCreate:
+		res.Err = kv.CreateNamespace(action.Key)
+	case NamespaceDelete:
+		res.Err = kv.DeleteNamespace(action.Key)
+	}
+
+	if res.Revision == 0 {
+		res.Revision = kv.Revision()
+	}
+
+	return res
+}
+
+// batchGrowth sums what the puts of a batch add to kv, reporting whether it
+// has any. Deletes in the same batch are not credited.
+func batchGrowth(kv *store.Store, actions []StoreAction) (store.Usage, bool) {
+	var growth store.Usage
+	puts := false
+	for a := range slices.Values(actions) {
+		if a.Action != Put {
+			continue
+		}
+
+		g := kv.Growth(a.Key, a.Value)
+		growth.Keys += g.Keys
+		growth.Bytes += g.Bytes
+		puts = true
+	}
+
+	return growth, puts
+}
+
+// authorizeLease checks the caller of a lease action against the lease as the
+// action applies, so keys attached since it was proposed are covered too. A
+// revocation also needs write access to every key it deletes.
+func (n RaftNode) authorizeLease(kv *store.Store, action StoreAction, revoke bool) error {
+	if action.Caller == "" || !n.keyValueStore.AuthEnabled() {
+		return nil
 	}
+
+	p, err := n.keyValueStore.PrincipalOf(action.Caller)
+	if err != nil {
+		return fmt.Errorf("user %q no longer exists: %w", action.Caller, store.PermissionDeniedError)
+	}
+
+	lease, err := kv.GetLease(action.Lease)
+	if err != nil {
+		return err
+	}
+
+	accesses := []store.Access{lease.UseAccess()}
+	if revoke {
+		for _, key := range lease.KeyList() {
+			accesses = append(accesses, store.KeyAccess(key, true))
+		}
+	}
+
+	for _, a := range accesses {
+		if !p.Permits(action.Namespace, a) {
+			return fmt.Errorf("user %q may not %s: %w", p.User, a, store.PermissionDeniedError)
+		}
+	}
+
+	return nil
+}
+
+// Propose replicates action and waits until it is applied on this node,
+// returning the outcome of applying it. Values too large for a single append
+// message are proposed ahead in chunks, reassembled when the put applies.
+func (n RaftNode) Propose(ctx context.Context, action StoreAction) (ActionResult, error) {
+	ctx, span := tracer.Start(ctx, "raft.propose", trace.WithAttributes(
+		attribute.Int("kv.action", action.Action),
+		attribute.String("kv.namespace", action.Namespace),
+	))
+	defer span.End()
+
+	res, err := n.propose(ctx, action)
+	if err != nil {
+		recordError(span, err)
+	}
+
+	return res, err
+}
+
+func (n RaftNode) propose(ctx context.Context, action StoreAction) (ActionResult, error) {
+	if n.maxValueBytes > 0 && exceedsValueSize(action, n.maxValueBytes) {
+		return ActionResult{}, ValueTooLargeError
+	}
+
+	id, ch, err := n.waiter.register(n.maxInflight)
+	if err != nil {
+		return ActionResult{}, err
+	}
+	defer n.waiter.cancel(id)
+
+	start := time.Now()
+	action.ID = id
+	action.Trace = traceContext(ctx)
+	if action.Action == Put && len(action.Value) > n.chunkSize {
+		if action, err = n.proposeChunks(ctx, action); err != nil {
+			n.abortUpload(ctx, id)
+			return ActionResult{}, err
+		}
+	}
+	data, err := EncodeAction(n.logger, action)
+	if err != nil {
+		return ActionResult{}, err
+	}
+
+	if err := n.RaftNode.Propose(ctx, data); err != nil {
+		if action.Upload != 0 {
+			n.abortUpload(ctx, action.Upload)
+		}
+		return ActionResult{}, n.proposeError(err)
+	}
+
+	select {
+	case res := <-ch:
+		proposalDuration.Observe(time.Since(start).Seconds())
+		return res, res.Err
+	case <-ctx.Done():
+		if action.Upload != 0 {
+			n.abortUpload(ctx, action.Upload)
+		}
+		return ActionResult{}, ctx.Err()
+	}
+}
+
+// abortUpload proposes dropping the chunks of an upload whose put failed to
+// be proposed or applied in time, so they stop taking room. If the put still
+// applies first the abort finds nothing left, and an abort that is lost leaves
+// the upload to be dropped once stale.
+func (n RaftNode) abortUpload(ctx context.Context, id uint64) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+
+	data, err := EncodeAction(n.logger, StoreAction{Action: ChunkAbort, Upload: id})
+	if err != nil {
+		return
+	}
+
+	if err := n.RaftNode.Propose(ctx, data); err != nil {
+		n.logger.Warn("could not abort upload", "upload", id, "err", err)
+	}
+}
+
+// proposeError tells a leader dropping proposals because its uncommitted log
+// is full apart from a node that has no leader to hand them to.
+func (n RaftNode) proposeError(err error) error {
+	if errors.Is(err, raft.ErrProposalDropped) && IsLeader(n.RaftNode) {
+		return fmt.Errorf("%w: the uncommitted log is full", OverloadedError)
+	}
+
+	return err
+}
+
+// exceedsValueSize reports whether action puts a value, directly or within a
+// batch, larger than limit bytes.
+func exceedsValueSize(action StoreAction, limit int) bool {
+	switch action.Action {
+	case Put:
+		return len(action.Value) > limit
+	case Batch:
+		return slices.ContainsFunc(action.Actions, func(a StoreAction) bool {
+			return exceedsValueSize(a, limit)
+		})
+	default:
+		return false
+	}
+}
+
+// proposeChunks proposes the value of action in chunks under the action's
+// ID, returning the put that assembles them.
+func (n RaftNode) proposeChunks(ctx context.Context, action StoreAction) (StoreAction, error) {
+	index := 0
+	for chunk := range slices.Chunk(action.Value, n.chunkSize) {
+		data, err := EncodeAction(n.logger, StoreAction{
+			Action: Chunk,
+			Upload: action.ID,
+			Chunks: index,
+			Value:  chunk,
+		})
+		if err != nil {
+			return StoreAction{}, err
+		}
+
+		if err := n.RaftNode.Propose(ctx, data); err != nil {
+			return StoreAction{}, n.proposeError(err)
+		}
+		index++
+	}
+
+	action.Value = nil
+	action.Upload, action.Chunks = action.ID, index
+
+	return action, nil
+}
+
+func (n RaftNode) AddMember(ctx context.Context, member Member) error {
+	if _, ok := n.members.Get(member.ID); ok {
+		return MemberExistsError
+	}
+
+	data, err := EncodeMember(n.logger, member)
+	if err != nil {
+		return err
+	}
+
+	return n.proposeConfChange(ctx, raftpb.ConfChange{
+		Type:    raftpb.ConfChangeAddNode,
+		NodeID:  member.ID,
+		Context: data,
+	})
+}
+
+func (n RaftNode) RemoveMember(ctx context.Context, ID uint64) error {
+	if _, ok := n.members.Get(ID); !ok {
+		return MemberNotFoundError
+	}
+
+	return n.proposeConfChange(ctx, raftpb.ConfChange{
+		Type:   raftpb.ConfChangeRemoveNode,
+		NodeID: ID,
+	})
+}
+
+func (n RaftNode) proposeConfChange(ctx context.Context, cc raftpb.ConfChange) error {
+	// Membership changes are rare and administrative, they bypass admission
+	// control so an overloaded cluster can still be reconfigured.
+	id, ch, _ := n.waiter.register(0)
+	defer n.waiter.cancel(id)
+
+	cc.ID = id
+	if err := n.RaftNode.ProposeConfChange(ctx, cc); err != nil {
+		return err
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TransferLeadership asks the leader to hand over to the member with ID and
+// waits until this node sees it leading.
+func (n RaftNode) TransferLeadership(ctx context.Context, ID uint64) error {
+	if _, ok := n.members.Get(ID); !ok {
+		return MemberNotFoundError
+	}
+
+	n.RaftNode.TransferLeadership(ctx, n.RaftNode.Status().Lead, ID)
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if n.RaftNode.Status().Lead == ID {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// SnapshotIndex returns the log index of the node's latest snapshot.
+func (n RaftNode) SnapshotIndex() uint64 {
+	snap, err := n.storage.Snapshot()
+	if err != nil {
+		return 0
+	}
+
+	return snap.Metadata.Index
+}
+
+// Reachable reports whether a message was exchanged with the member within
+// an election timeout. The node itself is always reachable.
+func (n RaftNode) Reachable(ID uint64) (bool, bool) {
+	if ID == n.RaftNode.Status().ID {
+		return true, true
+	}
+
+	last, ok := n.transport.LastContact(ID)
+	if !ok {
+		return false, false
+	}
+
+	return time.Since(last) < electionTimeout, true
+}
+
+// ExpireLoop proposes the expiry of keys and leases past their deadline while
+// this node is the leader. Followers never delete on their own clock, they
+// wait for the committed expiry entry.
+func (n RaftNode) ExpireLoop(ctx context.Context) {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	t := newExpiryTracker()
+	for {
+		select {
+		case <-ticker.C:
+			if !IsLeader(n.RaftNode) {
+				t.reset()
+				continue
+			}
+
+			now := time.Now()
+			n.expire(ctx, t, "", n.keyValueStore, now)
+			for info := range slices.Values(n.keyValueStore.Namespaces()) {
+				if ns, err := n.keyValueStore.Namespace(info.Name); err == nil {
+					n.expire(ctx, t, info.Name, ns, now)
+				}
+			}
+			t.sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// expire proposes the expiry of the keys and leases of a namespace's store
+// that are past their deadline, unless already in flight.
+func (n RaftNode) expire(ctx context.Context, t *expiryTracker, namespace string, kv *store.Store, now time.Time) {
+	actions := make([]StoreAction, 0)
+	for e := range slices.Values(kv.Expired(now.UnixNano())) {
+		actions = append(actions, StoreAction{
+			Action:    Expire,
+			Namespace: namespace,
+			Key:       e.Key,
+			Revision:  e.ModRevision,
+		})
+	}
+	for lease := range slices.Values(kv.ExpiredLeases(now.UnixNano())) {
+		actions = append(actions, StoreAction{
+			Action:    LeaseExpire,
+			Namespace: namespace,
+			Lease:     lease.ID,
+			ExpiresAt: lease.ExpiresAt,
+		})
+	}
+
+	for action := range slices.Values(actions) {
+		id := expiryOf(action)
+		if !t.propose(id, now) {
+			continue
+		}
+		if err := n.proposeExpire(ctx, action); err != nil {
+			t.failed(id)
+		}
+	}
+}
+
+func (n RaftNode) proposeExpire(ctx context.Context, action StoreAction) error {
+	a, err := EncodeAction(n.logger, action)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := n.RaftNode.Propose(ctx, a); err != nil {
+		n.logger.Error("error proposing expiry", "action", action, "err", err)
+		return err
+	}
+
+	return nil
 }
 
 func (n RaftNode) sendMessages(messages []raftpb.Message) {
@@ -159,8 +830,14 @@ func (n RaftNode) sendMessages(messages []raftpb.Message) {
 	defer cancel()
 
 	for message := range slices.Values(messages) {
-		n.logger.Debug("send message", "message", message, "peers", n.peers)
-		received := n.transport.Send(message, n.peers[message.To-1])
+		member, ok := n.members.Get(message.To)
+		if !ok {
+			n.logger.Error("message to unknown member", "to", message.To)
+			continue
+		}
+
+		n.logger.Debug("send message", "message", message, "to", member)
+		received := n.transport.Send(message, member.PeerAddr)
 		n.logger.Debug("receive message", "message", message)
 
 		n.RaftNode.Step(ctx, received)
@@ -6,9 +6,12 @@ import (
 	"log/slog"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"go.etcd.io/raft/v3/raftpb"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PeersLookup func(uint64) string
@@ -19,6 +22,7 @@ type TCPTransport struct {
 	peers          PeersLookup
 	messagesRxChan <-chan raftpb.Message
 	messagesTxChan chan<- raftpb.Message
+	contacts       *contacts
 }
 
 func NewTransport(
@@ -34,6 +38,7 @@ func NewTransport(
 		peers:          peers,
 		messagesRxChan: messagesRx,
 		messagesTxChan: messagesTx,
+		contacts:       newContacts(),
 	}
 }
 
@@ -57,9 +62,34 @@ func (t TCPTransport) ListenAndServe(ctx context.Context) {
 
 func (t TCPTransport) Send(message raftpb.Message, to string) raftpb.Message {
 	t.logger.Debug("transport", "step", "send message", "message", message, "to", to)
+	peer := peerLabel(message.To)
+
+	// Heartbeats and votes are too many to trace, only the messages
+	// replicating entries or snapshots are, linked to the proposals of the
+	// entries they carry.
+	span := trace.SpanFromContext(context.Background())
+	if len(message.Entries) > 0 || message.Type == raftpb.MsgSnap {
+		_, span = tracer.Start(context.Background(), "raft.transport.send",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("kv.raft.peer", peer),
+				attribute.String("kv.raft.message", message.Type.String()),
+				attribute.Int("kv.raft.entries", len(message.Entries)),
+			),
+		)
+		if span.IsRecording() {
+			for _, link := range entryTraceLinks(t.logger, message.Entries) {
+				span.AddLink(link)
+			}
+		}
+	}
+	defer span.End()
+
 	conn, err := net.Dial("tcp", to)
 	if err != nil {
 		t.logger.Debug("transport", "step", "send message", "err", err, "to", to)
+		transportErrors.WithLabelValues(peer).Inc()
+		recordError(span, err)
 		return raftpb.Message{}
 	}
 	defer conn.Close()
@@ -67,18 +97,30 @@ func (t TCPTransport) Send(message raftpb.Message, to string) raftpb.Message {
 	msg, err := proto.Marshal(&message)
 	if err != nil {
 		t.logger.Debug("transport", "step", "send message", "err", err)
+		transportErrors.WithLabelValues(peer).Inc()
+		recordError(span, err)
 		return raftpb.Message{}
 	}
 
 	t.logger.Debug("transport", "step", "send message", "message", msg)
-	if _, err := conn.Write(msg); err != nil {
+	written, err := conn.Write(msg)
+	transportSentBytes.WithLabelValues(peer).Add(float64(written))
+	span.SetAttributes(attribute.Int("kv.raft.bytes", written))
+	if err != nil {
 		t.logger.Debug("transport", "step", "send message", "err", err)
+		transportErrors.WithLabelValues(peer).Inc()
+		recordError(span, err)
 		return raftpb.Message{}
 	}
+	t.contacts.touch(message.To)
 
 	return message
 }
 
+func (t TCPTransport) LastContact(ID uint64) (time.Time, bool) {
+	return t.contacts.get(ID)
+}
+
 func (t TCPTransport) Listen() {
 	t.logger.Error("transport", "step", "init", "addr", t.addr)
 	l, err := net.Listen("tcp", t.addr)
@@ -109,7 +151,11 @@ func (t TCPTransport) ReadMessages(conn net.Conn) {
 	var msg raftpb.Message
 	if err := proto.Unmarshal(b, &msg); err != nil {
 		t.logger.Error("transport", "step", "unmarshaling", "err", err)
+		transportErrors.WithLabelValues(peerLabel(msg.From)).Inc()
+	} else {
+		t.contacts.touch(msg.From)
 	}
+	transportReceivedBytes.WithLabelValues(peerLabel(msg.From)).Add(float64(len(b)))
 
 	t.logger.Debug("transport", "step", "receive message", "message", msg)
 	t.messagesTxChan <- msg
@@ -0,0 +1,59 @@
+package raft
+
+import (
+	"context"
+	"log/slog"
+
+	"go.etcd.io/raft/v3/raftpb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/pablovarg/distributed-key-value-store/raft")
+
+// traceContext encodes the span of ctx for the entry envelope, nil when
+// there is none.
+func traceContext(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+
+	return carrier
+}
+
+// traceLink links to the span an entry envelope carries.
+func traceLink(carrier map[string]string) (trace.Link, bool) {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(carrier))
+	link := trace.LinkFromContext(ctx)
+
+	return link, link.SpanContext.IsValid()
+}
+
+// entryTraceLinks links to the proposals of the entries a message carries.
+func entryTraceLinks(l *slog.Logger, entries []raftpb.Entry) []trace.Link {
+	links := make([]trace.Link, 0)
+	for _, entry := range entries {
+		if entry.Type != raftpb.EntryNormal || entry.Data == nil {
+			continue
+		}
+
+		action, err := DecodeAction(l, entry.Data)
+		if err != nil || action.Trace == nil {
+			continue
+		}
+		if link, ok := traceLink(action.Trace); ok {
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
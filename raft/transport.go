@@ -2,6 +2,8 @@ package raft
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"go.etcd.io/raft/v3/raftpb"
 )
@@ -9,4 +11,32 @@ import (
 type Transporter interface {
 	Send(message raftpb.Message, to string) raftpb.Message
 	ListenAndServe(ctx context.Context)
+	// LastContact returns when a message was last sent to, or received
+	// from, the peer with ID, if ever.
+	LastContact(ID uint64) (time.Time, bool)
+}
+
+// contacts records when messages were last exchanged with each peer.
+type contacts struct {
+	mu   sync.Mutex
+	last map[uint64]time.Time
+}
+
+func newContacts() *contacts {
+	return &contacts{last: make(map[uint64]time.Time)}
+}
+
+func (c *contacts) touch(ID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.last[ID] = time.Now()
+}
+
+func (c *contacts) get(ID uint64) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.last[ID]
+	return t, ok
 }
@@ -0,0 +1,89 @@
+package raft
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+// ActionResult is the outcome of applying a StoreAction on this node.
+type ActionResult struct {
+	Revision int64
+	Lease    int64
+	Deleted  int64
+	Counter  int64
+	Batch    []store.BatchResult
+	Err      error
+}
+
+// Proposer proposes actions and waits for them to be applied locally.
+type Proposer interface {
+	Propose(ctx context.Context, action StoreAction) (ActionResult, error)
+}
+
+// waiter hands apply results back to the goroutine that proposed the entry.
+// Proposal IDs embed the node ID so entries proposed by other members never
+// match a local waiter.
+type waiter struct {
+	mu      sync.Mutex
+	nodeID  uint64
+	next    uint64
+	pending map[uint64]chan ActionResult
+}
+
+func newWaiter() *waiter {
+	return &waiter{
+		pending: make(map[uint64]chan ActionResult),
+	}
+}
+
+// register allocates a proposal ID, refusing it with OverloadedError while
+// limit proposals are already waiting. A limit of zero leaves it unbounded.
+func (w *waiter) register(limit int) (uint64, <-chan ActionResult, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if limit > 0 && len(w.pending) >= limit {
+		return 0, nil, OverloadedError
+	}
+
+	w.next++
+	id := w.nodeID<<48 | w.next
+	ch := make(chan ActionResult, 1)
+	w.pending[id] = ch
+
+	return id, ch, nil
+}
+
+// len returns how many proposals wait for their result.
+func (w *waiter) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.pending)
+}
+
+func (w *waiter) cancel(id uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.pending, id)
+}
+
+func (w *waiter) trigger(id uint64, res ActionResult) {
+	if id == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ch, ok := w.pending[id]
+	if !ok {
+		return
+	}
+
+	delete(w.pending, id)
+	ch <- res
+}
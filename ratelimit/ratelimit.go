@@ -0,0 +1,168 @@
+// Package ratelimit keeps the token buckets the HTTP, gRPC and Redis servers
+// limit their clients with, so a client is held to the same limits whichever
+// protocol it speaks.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket refilled with Rate tokens a second, holding at most
+// Burst. A zero Rate leaves requests unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (rl Limit) Unlimited() bool {
+	return rl.Rate <= 0
+}
+
+// Parse parses "rate:burst", or "off" for no limit. The burst defaults to the
+// rate, rounded up.
+func Parse(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "" {
+		return Limit{}, nil
+	}
+
+	rateStr, burstStr, hasBurst := strings.Cut(s, ":")
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || rate <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: rate is not a positive number", s)
+	}
+
+	burst := int(rate + 0.999)
+	if hasBurst {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("rate limit %q: burst is not a positive int", s)
+		}
+	}
+
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+// ParseRoutes parses "route=rate:burst" pairs separated by ";". Routes are
+// HTTP patterns as registered, such as "POST /values", full gRPC methods, such
+// as "/etcdserverpb.KV/Put", or Redis commands, such as "SET".
+func ParseRoutes(s string) (map[string]Limit, error) {
+	res := make(map[string]Limit)
+	for pair := range strings.SplitSeq(s, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		route, limit, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("route rate limit %q: missing =", pair)
+		}
+
+		rl, err := Parse(limit)
+		if err != nil {
+			return nil, err
+		}
+		res[strings.TrimSpace(route)] = rl
+	}
+
+	return res, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time elapsed since it was last used and
+// takes a token from it. When empty, it returns how long until a token is
+// available.
+func (b *bucket) take(rl Limit, now time.Time) (bool, time.Duration) {
+	b.tokens = min(float64(rl.Burst), b.tokens+now.Sub(b.last).Seconds()*rl.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / rl.Rate * float64(time.Second))
+}
+
+// bucketSweepInterval is how often buckets idle long enough to have refilled
+// are dropped.
+const bucketSweepInterval = time.Minute
+
+// Limiter keeps a token bucket per route and client. A nil Limiter leaves
+// every route unlimited.
+type Limiter struct {
+	def    Limit
+	routes map[string]Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter limits every route to def, unless routes overrides it.
+func NewLimiter(def Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{
+		def:       def,
+		routes:    routes,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Limit returns the limit of a route.
+func (l *Limiter) Limit(route string) Limit {
+	if l == nil {
+		return Limit{}
+	}
+
+	if rl, ok := l.routes[route]; ok {
+		return rl
+	}
+
+	return l.def
+}
+
+// Take takes a token from the bucket of client on route. When it is empty,
+// it returns how long until a token is available.
+func (l *Limiter) Take(route, client string) (bool, time.Duration) {
+	limit := l.Limit(route)
+	if limit.Unlimited() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > bucketSweepInterval {
+		l.sweep(now)
+	}
+
+	key := route + " " + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	return b.take(limit, now)
+}
+
+// sweep drops the buckets idle for longer than a sweep interval, which any
+// sensible limit refills whole. The caller must hold the lock.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketSweepInterval {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
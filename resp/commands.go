@@ -0,0 +1,642 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+const (
+	defaultScanCount = 10
+	// maxScanCount bounds the keys a single SCAN reads.
+	maxScanCount   = 1000
+	expireAttempts = 3
+)
+
+type command struct {
+	minArgs int
+	// maxArgs is unbounded when negative.
+	maxArgs int
+	// leader commands are refused by followers, as the HTTP API forwards
+	// them to the leader.
+	leader bool
+	// keys are the arguments naming keys, checked against the caller's
+	// permissions while auth is enabled.
+	keys keySpec
+	// write commands need write permission on their keys.
+	write bool
+	// noAuth commands may run before the connection authenticates.
+	noAuth bool
+	run    func(srv *Server, w *writer, args [][]byte)
+}
+
+type keySpec int
+
+const (
+	noKeys keySpec = iota
+	firstKey
+	allKeys
+	// wholeKeyspace commands may touch any key.
+	wholeKeyspace
+)
+
+var commands = map[string]command{
+	"PING":    {minArgs: 0, maxArgs: 1, run: (*Server).ping},
+	"AUTH":    {minArgs: 1, maxArgs: 2, noAuth: true, run: (*Server).auth},
+	"HELLO":   {minArgs: 0, maxArgs: -1, noAuth: true, run: (*Server).hello},
+	"CLIENT":  {minArgs: 1, maxArgs: -1, run: (*Server).client},
+	"SELECT":  {minArgs: 1, maxArgs: 1, run: (*Server).selectDB},
+	"COMMAND": {minArgs: 0, maxArgs: -1, run: (*Server).commandInfo},
+	"GET":     {minArgs: 1, maxArgs: 1, leader: true, keys: firstKey, run: (*Server).get},
+	"SET":     {minArgs: 2, maxArgs: -1, leader: true, keys: firstKey, write: true, run: (*Server).set},
+	"SETNX":   {minArgs: 2, maxArgs: 2, leader: true, keys: firstKey, write: true, run: (*Server).setnx},
+	"DEL":     {minArgs: 1, maxArgs: -1, leader: true, keys: allKeys, write: true, run: (*Server).del},
+	"EXISTS":  {minArgs: 1, maxArgs: -1, leader: true, keys: allKeys, run: (*Server).exists},
+	"MGET":    {minArgs: 1, maxArgs: -1, leader: true, keys: allKeys, run: (*Server).mget},
+	"INCR":    {minArgs: 1, maxArgs: 1, leader: true, keys: firstKey, write: true, run: (*Server).incr},
+	"EXPIRE":  {minArgs: 2, maxArgs: 2, leader: true, keys: firstKey, write: true, run: (*Server).expire},
+	"SCAN":    {minArgs: 1, maxArgs: -1, leader: true, keys: wholeKeyspace, run: (*Server).scan},
+}
+
+func (srv *Server) dispatch(w *writer, name string, args [][]byte) {
+	if !srv.allow(w, name) {
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		w.error(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+		return
+	}
+
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		w.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+
+	if !cmd.noAuth && !srv.authorize(w, name, cmd, args) {
+		return
+	}
+
+	if cmd.leader && !internalRaft.IsLeader(srv.n) {
+		srv.notLeader(w)
+		return
+	}
+
+	cmd.run(srv, w, args)
+}
+
+// allow takes a token for the command from the limiter shared with the HTTP
+// and gRPC servers, replying with the error when none is left. Clients are
+// told apart by the user of their token while auth is enabled, by their IP
+// otherwise.
+func (srv *Server) allow(w *writer, name string) bool {
+	limit := srv.limiter.Limit(name)
+	if limit.Unlimited() {
+		return true
+	}
+
+	client := "ip:" + w.remote
+	if w.token != "" && srv.s.AuthEnabled() {
+		if principal, err := authenticate(srv.s, w.token); err == nil {
+			client = "user:" + principal.User
+		}
+	}
+
+	if ok, _ := srv.limiter.Take(name, client); !ok {
+		srv.l.Info("resp rate limited", "command", name, "client", client)
+		w.error(fmt.Sprintf("ERR rate limit of %g commands a second exceeded", limit.Rate))
+		return false
+	}
+
+	return true
+}
+
+// authorize checks the connection's token grants cmd on its keys, replying
+// with the error otherwise. Every command is allowed while auth is disabled.
+func (srv *Server) authorize(w *writer, name string, cmd command, args [][]byte) bool {
+	if !srv.s.AuthEnabled() {
+		return true
+	}
+
+	if w.token == "" {
+		w.error("NOAUTH Authentication required.")
+		return false
+	}
+
+	principal, err := authenticate(srv.s, w.token)
+	if err != nil {
+		w.token = ""
+		w.error("NOAUTH Authentication required.")
+		return false
+	}
+
+	var keys [][]byte
+	switch cmd.keys {
+	case firstKey:
+		keys = args[:1]
+	case allKeys:
+		keys = args
+	case wholeKeyspace:
+		if !principal.Permits("", store.Access{Write: cmd.write}) {
+			w.error(fmt.Sprintf("NOPERM User %s has no permissions to run the '%s' command", principal.User, strings.ToLower(name)))
+			return false
+		}
+	}
+
+	for _, key := range keys {
+		if !principal.Permits("", store.KeyAccess(string(key), cmd.write)) {
+			w.error(fmt.Sprintf("NOPERM User %s has no permissions to access the '%s' key", principal.User, key))
+			return false
+		}
+	}
+
+	return true
+}
+
+func authenticate(s *store.Store, token string) (store.Principal, error) {
+	id, secret, _ := strings.Cut(token, ".")
+	return s.Authenticate(id, secret, time.Now().UnixNano())
+}
+
+// auth authenticates the connection with a token, given as the password. The
+// user name, if any, is ignored since the token names its user.
+func (srv *Server) auth(w *writer, args [][]byte) {
+	token := string(args[len(args)-1])
+	if _, err := authenticate(srv.s, token); err != nil {
+		w.error("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+
+	w.token = token
+	w.simple("OK")
+}
+
+// notLeader points the client at the leader's API, since followers only
+// forward HTTP requests.
+func (srv *Server) notLeader(w *writer) {
+	lead := srv.n.Status().Lead
+	if lead == 0 {
+		w.error("CLUSTERDOWN no leader elected")
+		return
+	}
+
+	leader, _ := srv.members.Get(lead)
+	w.error(fmt.Sprintf("READONLY not the leader, member %d at %s is", lead, leader.ClientURL))
+}
+
+// proposalError replies with the Redis error closest to a failed proposal.
+func (srv *Server) proposalError(w *writer, name string, err error) {
+	switch {
+	case errors.Is(err, store.NotIntegerError):
+		w.error("ERR value is not an integer or out of range")
+	case errors.Is(err, store.OverflowError):
+		w.error("ERR increment or decrement would overflow")
+	case errors.Is(err, internalRaft.ValueTooLargeError):
+		w.error("ERR value exceeds the maximum size")
+	case errors.Is(err, store.NoSpaceError):
+		w.error("OOM " + err.Error())
+	case errors.Is(err, internalRaft.OverloadedError), errors.Is(err, store.UploadsFullError):
+		w.error("TRYAGAIN " + err.Error())
+	case errors.Is(err, store.ChunksMissingError):
+		w.error("TRYAGAIN value chunks were lost to a leader change")
+	case errors.Is(err, context.DeadlineExceeded):
+		w.error("TRYAGAIN proposal timed out")
+	default:
+		srv.l.Error("resp internal error", "command", name, "error", err.Error())
+		w.error("ERR internal error")
+	}
+}
+
+func (srv *Server) propose(action internalRaft.StoreAction) (internalRaft.ActionResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), proposalTimeout)
+	defer cancel()
+
+	return srv.p.Propose(ctx, action)
+}
+
+func (srv *Server) ping(w *writer, args [][]byte) {
+	if len(args) == 1 {
+		w.bulk(args[0])
+		return
+	}
+
+	w.simple("PONG")
+}
+
+// hello negotiates the protocol version, authenticating the connection when
+// given AUTH. SETNAME is accepted and ignored.
+func (srv *Server) hello(w *writer, args [][]byte) {
+	for i := 1; i < len(args); i++ {
+		if strings.ToUpper(string(args[i])) != "AUTH" {
+			continue
+		}
+		if i+2 >= len(args) {
+			w.error("ERR syntax error")
+			return
+		}
+		if _, err := authenticate(srv.s, string(args[i+2])); err != nil {
+			w.error("WRONGPASS invalid username-password pair or user is disabled.")
+			return
+		}
+		w.token = string(args[i+2])
+		i += 2
+	}
+
+	if srv.s.AuthEnabled() && w.token == "" {
+		w.error("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		return
+	}
+
+	if len(args) > 0 {
+		proto, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			w.error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if proto != 2 && proto != 3 {
+			w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		w.proto = proto
+	}
+
+	role := "replica"
+	if internalRaft.IsLeader(srv.n) {
+		role = "master"
+	}
+
+	w.mapHeader(6)
+	w.bulkString("server")
+	w.bulkString("distributed-key-value-store")
+	w.bulkString("version")
+	w.bulkString("1.0.0")
+	w.bulkString("proto")
+	w.integer(int64(w.proto))
+	w.bulkString("mode")
+	w.bulkString("standalone")
+	w.bulkString("role")
+	w.bulkString(role)
+	w.bulkString("modules")
+	w.array(0)
+}
+
+// client accepts the connection metadata client libraries send on connect.
+func (srv *Server) client(w *writer, args [][]byte) {
+	switch strings.ToUpper(string(args[0])) {
+	case "SETNAME", "SETINFO":
+		w.simple("OK")
+	default:
+		w.error(fmt.Sprintf("ERR unknown subcommand '%s'", args[0]))
+	}
+}
+
+// selectDB only accepts the default database, the store has a single
+// keyspace.
+func (srv *Server) selectDB(w *writer, args [][]byte) {
+	if string(args[0]) != "0" {
+		w.error("ERR DB index is out of range")
+		return
+	}
+
+	w.simple("OK")
+}
+
+func (srv *Server) commandInfo(w *writer, args [][]byte) {
+	w.array(0)
+}
+
+func (srv *Server) get(w *writer, args [][]byte) {
+	value, err := srv.s.Get(string(args[0]))
+	if err != nil {
+		w.null()
+		return
+	}
+
+	w.bulk(value)
+}
+
+// set supports the NX, XX, EX and PX options. A put that NX or XX prevents
+// replies with a null.
+func (srv *Server) set(w *writer, args [][]byte) {
+	action := internalRaft.StoreAction{
+		Action: internalRaft.Put,
+		Key:    string(args[0]),
+		Value:  args[1],
+	}
+
+	for i := 2; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch opt {
+		case "NX", "XX":
+			if action.Existence != store.AnyExistence {
+				w.error("ERR syntax error")
+				return
+			}
+			action.Existence = store.MustNotExist
+			if opt == "XX" {
+				action.Existence = store.MustExist
+			}
+		case "EX", "PX":
+			if action.ExpiresAt != 0 || i+1 == len(args) {
+				w.error("ERR syntax error")
+				return
+			}
+			i++
+
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+			if n <= 0 {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			var ok bool
+			if action.ExpiresAt, ok = deadline(n, unit); !ok {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	if _, err := srv.propose(action); err != nil {
+		if errors.Is(err, store.PreconditionFailedError) {
+			w.null()
+			return
+		}
+		srv.proposalError(w, "SET", err)
+		return
+	}
+
+	w.simple("OK")
+}
+
+// setnx is the legacy form of SET NX some clients still send, replying
+// whether the key was created.
+func (srv *Server) setnx(w *writer, args [][]byte) {
+	_, err := srv.propose(internalRaft.StoreAction{
+		Action:    internalRaft.Put,
+		Key:       string(args[0]),
+		Value:     args[1],
+		Existence: store.MustNotExist,
+	})
+	switch {
+	case err == nil:
+		w.integer(1)
+	case errors.Is(err, store.PreconditionFailedError):
+		w.integer(0)
+	default:
+		srv.proposalError(w, "SETNX", err)
+	}
+}
+
+// del deletes every key in a single replicated batch, replying with how many
+// existed.
+func (srv *Server) del(w *writer, args [][]byte) {
+	ops := make([]internalRaft.StoreAction, 0, len(args))
+	for _, key := range args {
+		ops = append(ops, internalRaft.StoreAction{
+			Action: internalRaft.Delete,
+			Key:    string(key),
+		})
+	}
+
+	res, err := srv.propose(internalRaft.StoreAction{
+		Action:  internalRaft.Batch,
+		Actions: ops,
+	})
+	if err != nil {
+		srv.proposalError(w, "DEL", err)
+		return
+	}
+
+	var deleted int64
+	for _, r := range res.Batch {
+		if r.Found {
+			deleted++
+		}
+	}
+
+	w.integer(deleted)
+}
+
+func (srv *Server) exists(w *writer, args [][]byte) {
+	found, _, _ := srv.s.GetMany(keyStrings(args))
+
+	existing := make(map[string]bool, len(found))
+	for _, e := range found {
+		existing[e.Key] = true
+	}
+
+	// Repeated keys are counted every time, as Redis does.
+	var count int64
+	for _, key := range args {
+		if existing[string(key)] {
+			count++
+		}
+	}
+
+	w.integer(count)
+}
+
+// mget reads every key at the same revision.
+func (srv *Server) mget(w *writer, args [][]byte) {
+	found, _, _ := srv.s.GetMany(keyStrings(args))
+
+	values := make(map[string][]byte, len(found))
+	for _, e := range found {
+		values[e.Key] = e.Value
+	}
+
+	w.array(len(args))
+	for _, key := range args {
+		value, ok := values[string(key)]
+		if !ok {
+			w.null()
+			continue
+		}
+		w.bulk(value)
+	}
+}
+
+func (srv *Server) incr(w *writer, args [][]byte) {
+	res, err := srv.propose(internalRaft.StoreAction{
+		Action: internalRaft.Increment,
+		Key:    string(args[0]),
+		Delta:  1,
+	})
+	if err != nil {
+		srv.proposalError(w, "INCR", err)
+		return
+	}
+
+	w.integer(res.Counter)
+}
+
+// expire sets a key's deadline by writing its value back with it, guarded by
+// its mod revision so a concurrent write is never overwritten. Deadlines that
+// already passed delete the key, as Redis does.
+func (srv *Server) expire(w *writer, args [][]byte) {
+	key := string(args[0])
+
+	seconds, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+
+	expiresAt, ok := deadline(seconds, time.Second)
+	if seconds > 0 && !ok {
+		w.error("ERR invalid expire time in 'expire' command")
+		return
+	}
+
+	for range expireAttempts {
+		e, err := srv.s.GetEntry(key)
+		if err != nil {
+			w.integer(0)
+			return
+		}
+
+		action := internalRaft.StoreAction{
+			Action:      internalRaft.Put,
+			Key:         key,
+			Value:       e.Value,
+			ContentType: e.ContentType,
+			Lease:       e.Lease,
+			ExpiresAt:   expiresAt,
+			Revision:    e.ModRevision,
+		}
+		if seconds <= 0 {
+			action = internalRaft.StoreAction{
+				Action:   internalRaft.Delete,
+				Key:      key,
+				Revision: e.ModRevision,
+			}
+		}
+
+		_, err = srv.propose(action)
+		switch {
+		case err == nil:
+			w.integer(1)
+			return
+		case errors.Is(err, store.PreconditionFailedError), errors.Is(err, store.KeyNotFoundError):
+			continue
+		default:
+			srv.proposalError(w, "EXPIRE", err)
+			return
+		}
+	}
+
+	w.error("TRYAGAIN key kept changing")
+}
+
+// deadline is n units from now in unix nanoseconds, n being positive. It is
+// not ok when that does not fit in an int64.
+func deadline(n int64, unit time.Duration) (int64, bool) {
+	now := time.Now().UnixNano()
+	if n > (math.MaxInt64-now)/int64(unit) {
+		return 0, false
+	}
+
+	return now + n*int64(unit), true
+}
+
+// scan pages through the keys in order. A cursor resumes after the last key
+// of the page before it, so keys written or deleted meanwhile are neither
+// repeated nor make others skipped.
+func (srv *Server) scan(w *writer, args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		w.error("ERR invalid cursor")
+		return
+	}
+
+	match, count, typ := "", defaultScanCount, ""
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			w.error("ERR syntax error")
+			return
+		}
+
+		value := string(args[i+1])
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			match = value
+		case "COUNT":
+			if count, err = strconv.Atoi(value); err != nil || count <= 0 {
+				w.error("ERR value is not an integer or out of range")
+				return
+			}
+			count = min(count, maxScanCount)
+		case "TYPE":
+			typ = strings.ToLower(value)
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	start := ""
+	if cursor != 0 {
+		after, ok := srv.cursors.get(cursor)
+		if !ok {
+			w.error("ERR invalid cursor")
+			return
+		}
+		start = after + "\x00"
+	}
+
+	var (
+		entries []store.Entry
+		more    bool
+	)
+	if typ == "" || typ == "string" {
+		entries, _, more = srv.s.Range(start, "", count)
+	}
+
+	var next uint64
+	if more {
+		next = srv.cursors.put(entries[len(entries)-1].Key)
+	}
+
+	keys := make([][]byte, 0, len(entries))
+	for _, e := range entries {
+		if match == "" || globMatch(match, e.Key) {
+			keys = append(keys, []byte(e.Key))
+		}
+	}
+
+	w.array(2)
+	w.bulkString(strconv.FormatUint(next, 10))
+	w.array(len(keys))
+	for _, key := range keys {
+		w.bulk(key)
+	}
+}
+
+func keyStrings(args [][]byte) []string {
+	keys := make([]string, 0, len(args))
+	for _, key := range args {
+		keys = append(keys, string(key))
+	}
+
+	return keys
+}
@@ -0,0 +1,48 @@
+package resp
+
+import "sync"
+
+// maxScanCursors bounds how many SCAN iterations are remembered at once, the
+// oldest being forgotten first.
+const maxScanCursors = 4096
+
+// scanCursors maps the cursors handed out by SCAN to the last key of the page
+// they follow. Clients parse cursors as integers, so the key itself cannot be
+// handed out.
+type scanCursors struct {
+	mu   sync.Mutex
+	last uint64
+	keys map[uint64]string
+	// order lists the live cursors from the oldest.
+	order []uint64
+}
+
+func newScanCursors() *scanCursors {
+	return &scanCursors{keys: make(map[uint64]string)}
+}
+
+// put returns a new cursor resuming after key.
+func (c *scanCursors) put(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.order) == maxScanCursors {
+		delete(c.keys, c.order[0])
+		c.order = c.order[1:]
+	}
+
+	c.last++
+	c.keys[c.last] = key
+	c.order = append(c.order, c.last)
+
+	return c.last
+}
+
+// get returns the key a cursor resumes after, if it is still remembered.
+func (c *scanCursors) get(cursor uint64) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.keys[cursor]
+	return key, ok
+}
@@ -0,0 +1,178 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxBulkLen bounds a single argument, values being limited by the
+	// raft entry they are proposed in anyway.
+	maxBulkLen = 64 << 20
+	maxArgs    = 1 << 20
+	maxInline  = 64 << 10
+	// bulkChunk is read at a time, so a length alone does not make a bulk
+	// string allocate.
+	bulkChunk = 64 << 10
+)
+
+var ProtocolError = errors.New("protocol error")
+
+// limits bound the commands readCommand accepts.
+type limits struct {
+	args    int
+	bulkLen int
+}
+
+var (
+	authenticatedLimits = limits{args: maxArgs, bulkLen: maxBulkLen}
+	// unauthenticatedLimits leave room for AUTH and HELLO only, as Redis
+	// does, until the connection authenticates.
+	unauthenticatedLimits = limits{args: 10, bulkLen: 16 << 10}
+)
+
+// readCommand reads a command sent either as an array of bulk strings, as
+// every client library does, or inline as space separated words. Buffers
+// grow as the data arrives rather than by the lengths announced.
+func readCommand(r *bufio.Reader, lim limits) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		fields := strings.Fields(string(line))
+		args := make([][]byte, 0, len(fields))
+		for _, f := range fields {
+			args = append(args, []byte(f))
+		}
+		return args, nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > lim.args {
+		return nil, fmt.Errorf("%w: invalid multibulk length", ProtocolError)
+	}
+
+	args := make([][]byte, 0, min(max(n, 0), 16))
+	for range n {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got '%s'", ProtocolError, line)
+		}
+
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > lim.bulkLen {
+			return nil, fmt.Errorf("%w: invalid bulk length", ProtocolError)
+		}
+
+		arg, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	return args, nil
+}
+
+// readBulk reads a bulk string of size bytes and its terminator, a chunk at a
+// time.
+func readBulk(r *bufio.Reader, size int) ([]byte, error) {
+	arg := make([]byte, 0, min(size+2, bulkChunk))
+	for len(arg) < size+2 {
+		n := min(size+2-len(arg), bulkChunk)
+		arg = slices.Grow(arg, n)
+		if _, err := io.ReadFull(r, arg[len(arg):len(arg)+n]); err != nil {
+			return nil, err
+		}
+		arg = arg[:len(arg)+n]
+	}
+
+	if arg[size] != '\r' || arg[size+1] != '\n' {
+		return nil, fmt.Errorf("%w: bulk string not terminated", ProtocolError)
+	}
+
+	return arg[:size], nil
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("%w: line longer than %d bytes", ProtocolError, maxInline)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.TrimRight(string(line), "\r\n")), nil
+}
+
+// writer encodes replies in the protocol version negotiated through HELLO,
+// RESP2 until then. It also holds the token the connection authenticated
+// with, checked again on every command so revocations apply at once, and the
+// IP of the client.
+type writer struct {
+	w      *bufio.Writer
+	proto  int
+	token  string
+	remote string
+}
+
+func (w *writer) simple(s string) {
+	fmt.Fprintf(w.w, "+%s\r\n", s)
+}
+
+// error writes an error reply, msg starting with its error code like ERR.
+func (w *writer) error(msg string) {
+	fmt.Fprintf(w.w, "-%s\r\n", msg)
+}
+
+func (w *writer) integer(n int64) {
+	fmt.Fprintf(w.w, ":%d\r\n", n)
+}
+
+func (w *writer) bulk(b []byte) {
+	fmt.Fprintf(w.w, "$%d\r\n", len(b))
+	w.w.Write(b)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) bulkString(s string) {
+	w.bulk([]byte(s))
+}
+
+func (w *writer) null() {
+	if w.proto >= 3 {
+		w.w.WriteString("_\r\n")
+		return
+	}
+
+	w.w.WriteString("$-1\r\n")
+}
+
+func (w *writer) array(n int) {
+	fmt.Fprintf(w.w, "*%d\r\n", n)
+}
+
+// mapHeader starts a map of n pairs, written as a flat array in RESP2.
+func (w *writer) mapHeader(n int) {
+	if w.proto >= 3 {
+		fmt.Fprintf(w.w, "%%%d\r\n", n)
+		return
+	}
+
+	w.array(2 * n)
+}
+
+func (w *writer) flush() error {
+	return w.w.Flush()
+}
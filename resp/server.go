@@ -0,0 +1,170 @@
+// Package resp serves a subset of the Redis protocol, RESP2 and RESP3, on top
+// of the same raft proposals and store as the HTTP API, so Redis clients can
+// use the store unchanged.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/ratelimit"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/raft/v3"
+)
+
+const proposalTimeout = 5 * time.Second
+
+type Server struct {
+	l       *slog.Logger
+	addr    string
+	n       raft.Node
+	p       internalRaft.Proposer
+	s       *store.Store
+	members *internalRaft.Members
+	limiter *ratelimit.Limiter
+	cursors *scanCursors
+}
+
+func NewServer(
+	l *slog.Logger,
+	addr string,
+	n raft.Node,
+	p internalRaft.Proposer,
+	s *store.Store,
+	members *internalRaft.Members,
+	limiter *ratelimit.Limiter,
+) *Server {
+	return &Server{
+		l:       l,
+		addr:    addr,
+		n:       n,
+		p:       p,
+		s:       s,
+		members: members,
+		limiter: limiter,
+		cursors: newScanCursors(),
+	}
+}
+
+// ListenAndServe accepts connections until ctx is done, then closes the
+// listener and every open connection.
+func (srv *Server) ListenAndServe(ctx context.Context) error {
+	lis, err := net.Listen("tcp", srv.addr)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu    sync.Mutex
+		conns = make(map[net.Conn]struct{})
+		wg    sync.WaitGroup
+	)
+
+	go func() {
+		<-ctx.Done()
+		lis.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		for conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		conns[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+			}()
+			// A command gone wrong only drops its own connection.
+			defer func() {
+				if err := recover(); err != nil {
+					srv.l.Error("resp connection panicked", "remote", conn.RemoteAddr(), "err", err, "stack", string(debug.Stack()))
+				}
+			}()
+
+			srv.serve(conn)
+		}()
+	}
+}
+
+func (srv *Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReaderSize(conn, maxInline)
+	w := &writer{w: bufio.NewWriter(conn), proto: 2, remote: remoteIP(conn)}
+
+	for {
+		lim := unauthenticatedLimits
+		if w.token != "" || !srv.s.AuthEnabled() {
+			lim = authenticatedLimits
+		}
+
+		args, err := readCommand(r, lim)
+		if err != nil {
+			if errors.Is(err, ProtocolError) {
+				w.error("ERR " + err.Error())
+				w.flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				srv.l.Debug("resp connection closed", "remote", conn.RemoteAddr(), "err", err)
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		name := strings.ToUpper(string(args[0]))
+		if name == "QUIT" {
+			w.simple("OK")
+			w.flush()
+			return
+		}
+
+		srv.l.Debug("resp hit", "command", name)
+		srv.dispatch(w, name, args[1:])
+
+		// Pipelined commands are answered together.
+		if r.Buffered() == 0 {
+			if err := w.flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+
+	return host
+}
@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"context"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/rpc/pb"
+)
+
+type clusterServer struct {
+	pb.UnimplementedClusterServer
+	*server
+}
+
+func (s clusterServer) Status(ctx context.Context, req *pb.StatusRequest) (*pb.StatusResponse, error) {
+	status := s.n.Status()
+
+	return &pb.StatusResponse{
+		Id:        status.ID,
+		Leader:    status.Lead,
+		Term:      status.Term,
+		Commit:    status.Commit,
+		Applied:   status.Applied,
+		RaftState: status.RaftState.String(),
+	}, nil
+}
+
+func (s clusterServer) MemberList(ctx context.Context, req *pb.MemberListRequest) (*pb.MemberListResponse, error) {
+	lead := s.n.Status().Lead
+
+	res := &pb.MemberListResponse{Leader: lead}
+	for _, member := range s.members.List() {
+		res.Members = append(res.Members, &pb.Member{
+			Id:        member.ID,
+			PeerAddr:  member.PeerAddr,
+			ClientUrl: member.ClientURL,
+			IsLeader:  member.ID == lead,
+		})
+	}
+
+	return res, nil
+}
+
+func (s clusterServer) MemberAdd(ctx context.Context, req *pb.MemberAddRequest) (*pb.Member, error) {
+	if req.Id == 0 || req.PeerAddr == "" || req.ClientUrl == "" {
+		return nil, invalidArgument("id, peer_addr and client_url are required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	if err := s.c.AddMember(ctx, internalRaft.Member{
+		ID:        req.Id,
+		PeerAddr:  req.PeerAddr,
+		ClientURL: req.ClientUrl,
+	}); err != nil {
+		return nil, toStatus(s.l, "MemberAdd", err)
+	}
+
+	return &pb.Member{Id: req.Id, PeerAddr: req.PeerAddr, ClientUrl: req.ClientUrl}, nil
+}
+
+func (s clusterServer) MemberRemove(ctx context.Context, req *pb.MemberRemoveRequest) (*pb.MemberRemoveResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	if err := s.c.RemoveMember(ctx, req.Id); err != nil {
+		return nil, toStatus(s.l, "MemberRemove", err)
+	}
+
+	return &pb.MemberRemoveResponse{}, nil
+}
+
+func (s clusterServer) LeaderTransfer(ctx context.Context, req *pb.LeaderTransferRequest) (*pb.LeaderTransferResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	if err := s.c.TransferLeadership(ctx, req.Id); err != nil {
+		return nil, toStatus(s.l, "LeaderTransfer", err)
+	}
+
+	return &pb.LeaderTransferResponse{Leader: req.Id}, nil
+}
@@ -0,0 +1,391 @@
+package rpc
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// guardedAttempts bounds the retries of writes guarded by what was read
+// before proposing them, when a concurrent write invalidates the read.
+const guardedAttempts = 5
+
+var errTooManyConflicts = status.Error(codes.Aborted, "etcdserver: too many conflicting writes, retry")
+
+// etcdKVServer implements the subset of etcd's KV service the store supports.
+// The store keeps no history, so reads at past revisions fail as compacted.
+type etcdKVServer struct {
+	etcdserverpb.UnimplementedKVServer
+	*server
+}
+
+func (s *server) etcdHeader(revision int64) *etcdserverpb.ResponseHeader {
+	status := s.n.Status()
+
+	return &etcdserverpb.ResponseHeader{
+		MemberId: status.ID,
+		Revision: revision,
+		RaftTerm: status.Term,
+	}
+}
+
+func newEtcdKeyValue(e store.Entry) *mvccpb.KeyValue {
+	return &mvccpb.KeyValue{
+		Key:            []byte(e.Key),
+		CreateRevision: e.CreateRevision,
+		ModRevision:    e.ModRevision,
+		Version:        e.Version,
+		Value:          e.Value,
+		Lease:          e.Lease,
+	}
+}
+
+// etcdRange resolves etcd's key and range end into [start, end). An empty
+// range end selects the key alone and "\x00" every key from it on.
+func etcdRange(key, rangeEnd []byte) (start, end string, single bool) {
+	switch {
+	case len(rangeEnd) == 0:
+		return string(key), singleKeyEnd(string(key)), true
+	case bytes.Equal(rangeEnd, []byte{0}):
+		return string(key), "", false
+	default:
+		return string(key), string(rangeEnd), false
+	}
+}
+
+// singleKeyEnd is the end of the range holding key alone.
+func singleKeyEnd(key string) string {
+	return key + "\x00"
+}
+
+// etcdStatus maps the errors of the store and the raft node to the statuses
+// etcd clients expect.
+func etcdStatus(l *slog.Logger, method string, err error) error {
+	switch {
+	case errors.Is(err, store.LeaseNotFoundError):
+		return rpctypes.ErrGRPCLeaseNotFound
+	case errors.Is(err, store.KeyNotFoundError):
+		return rpctypes.ErrGRPCKeyNotFound
+	case errors.Is(err, store.PermissionDeniedError):
+		return rpctypes.ErrGRPCPermissionDenied
+	case errors.Is(err, store.RevisionCompactedError):
+		return rpctypes.ErrGRPCCompacted
+	case errors.Is(err, internalRaft.ValueTooLargeError):
+		return rpctypes.ErrGRPCRequestTooLarge
+	case errors.Is(err, store.NoSpaceError):
+		return rpctypes.ErrGRPCNoSpace
+	case errors.Is(err, internalRaft.OverloadedError), errors.Is(err, store.UploadsFullError):
+		return rpctypes.ErrGRPCRequestTooManyRequests
+	case errors.Is(err, context.DeadlineExceeded):
+		return rpctypes.ErrGRPCTimeout
+	default:
+		return toStatus(l, method, err)
+	}
+}
+
+// checkRevision rejects reads at any revision but the current one.
+func checkRevision(requested, current int64) error {
+	switch {
+	case requested <= 0 || requested == current:
+		return nil
+	case requested > current:
+		return rpctypes.ErrGRPCFutureRev
+	default:
+		return rpctypes.ErrGRPCCompacted
+	}
+}
+
+func (s etcdKVServer) Range(ctx context.Context, req *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	return s.etcdRange(req)
+}
+
+// etcdRange serves a range request from the current state of the store.
+func (s *server) etcdRange(req *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	start, end, single := etcdRange(req.Key, req.RangeEnd)
+
+	var entries []store.Entry
+	var revision int64
+	if single {
+		entries, _, revision = s.s.GetMany([]string{start})
+	} else {
+		entries, revision, _ = s.s.Range(start, end, 0)
+	}
+
+	if err := checkRevision(req.Revision, revision); err != nil {
+		return nil, err
+	}
+
+	return s.rangeResponse(req, entries, revision)
+}
+
+// rangeResponse filters, sorts and limits the entries read for req.
+func (s *server) rangeResponse(
+	req *etcdserverpb.RangeRequest,
+	entries []store.Entry,
+	revision int64,
+) (*etcdserverpb.RangeResponse, error) {
+	entries = slices.DeleteFunc(entries, func(e store.Entry) bool {
+		return (req.MinModRevision > 0 && e.ModRevision < req.MinModRevision) ||
+			(req.MaxModRevision > 0 && e.ModRevision > req.MaxModRevision) ||
+			(req.MinCreateRevision > 0 && e.CreateRevision < req.MinCreateRevision) ||
+			(req.MaxCreateRevision > 0 && e.CreateRevision > req.MaxCreateRevision)
+	})
+
+	if err := sortEntries(entries, req.SortOrder, req.SortTarget); err != nil {
+		return nil, err
+	}
+
+	res := &etcdserverpb.RangeResponse{
+		Header: s.etcdHeader(revision),
+		Count:  int64(len(entries)),
+	}
+	if req.CountOnly {
+		return res, nil
+	}
+
+	if req.Limit > 0 && int64(len(entries)) > req.Limit {
+		entries = entries[:req.Limit]
+		res.More = true
+	}
+
+	res.Kvs = make([]*mvccpb.KeyValue, 0, len(entries))
+	for _, e := range entries {
+		kv := newEtcdKeyValue(e)
+		if req.KeysOnly {
+			kv.Value = nil
+		}
+		res.Kvs = append(res.Kvs, kv)
+	}
+
+	return res, nil
+}
+
+// sortEntries orders entries as etcd does, ascending by key unless told
+// otherwise. Entries come sorted by key already.
+func sortEntries(
+	entries []store.Entry,
+	order etcdserverpb.RangeRequest_SortOrder,
+	target etcdserverpb.RangeRequest_SortTarget,
+) error {
+	if order == etcdserverpb.RangeRequest_NONE {
+		if target == etcdserverpb.RangeRequest_KEY {
+			return nil
+		}
+		order = etcdserverpb.RangeRequest_ASCEND
+	}
+
+	var compare func(a, b store.Entry) int
+	switch target {
+	case etcdserverpb.RangeRequest_KEY:
+		compare = func(a, b store.Entry) int { return cmp.Compare(a.Key, b.Key) }
+	case etcdserverpb.RangeRequest_VERSION:
+		compare = func(a, b store.Entry) int { return cmp.Compare(a.Version, b.Version) }
+	case etcdserverpb.RangeRequest_CREATE:
+		compare = func(a, b store.Entry) int { return cmp.Compare(a.CreateRevision, b.CreateRevision) }
+	case etcdserverpb.RangeRequest_MOD:
+		compare = func(a, b store.Entry) int { return cmp.Compare(a.ModRevision, b.ModRevision) }
+	case etcdserverpb.RangeRequest_VALUE:
+		compare = func(a, b store.Entry) int { return bytes.Compare(a.Value, b.Value) }
+	default:
+		return rpctypes.ErrGRPCInvalidSortOption
+	}
+
+	switch order {
+	case etcdserverpb.RangeRequest_ASCEND:
+		slices.SortStableFunc(entries, compare)
+	case etcdserverpb.RangeRequest_DESCEND:
+		slices.SortStableFunc(entries, func(a, b store.Entry) int { return compare(b, a) })
+	default:
+		return rpctypes.ErrGRPCInvalidSortOption
+	}
+
+	return nil
+}
+
+// Put writes the key in a single proposal. When the previous entry is asked
+// for, it is read by the same batch. When its value or lease are kept, the
+// put is guarded by the entry read beforehand, and retried if a concurrent
+// write changes it.
+func (s etcdKVServer) Put(ctx context.Context, req *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	if len(req.Key) == 0 {
+		return nil, rpctypes.ErrGRPCEmptyKey
+	}
+	if req.IgnoreValue && len(req.Value) != 0 {
+		return nil, rpctypes.ErrGRPCValueProvided
+	}
+	if req.IgnoreLease && req.Lease != 0 {
+		return nil, rpctypes.ErrGRPCLeaseProvided
+	}
+
+	if req.IgnoreValue || req.IgnoreLease {
+		return s.putKeeping(ctx, req)
+	}
+
+	action := internalRaft.StoreAction{
+		Action: internalRaft.Put,
+		Key:    string(req.Key),
+		Value:  req.Value,
+		Lease:  req.Lease,
+	}
+
+	if !req.PrevKv {
+		res, err := s.propose(ctx, action)
+		if err != nil {
+			return nil, etcdStatus(s.l, "etcd Put", err)
+		}
+
+		return &etcdserverpb.PutResponse{Header: s.etcdHeader(res.Revision)}, nil
+	}
+
+	res, err := s.propose(ctx, internalRaft.StoreAction{
+		Action: internalRaft.Batch,
+		Actions: []internalRaft.StoreAction{
+			{Action: internalRaft.Range, Key: action.Key, RangeEnd: singleKeyEnd(action.Key)},
+			action,
+		},
+	})
+	if err != nil {
+		return nil, etcdStatus(s.l, "etcd Put", err)
+	}
+
+	out := &etcdserverpb.PutResponse{Header: s.etcdHeader(res.Revision)}
+	if prev := res.Batch[0].Entries; len(prev) != 0 {
+		out.PrevKv = newEtcdKeyValue(prev[0])
+	}
+
+	return out, nil
+}
+
+// putKeeping serves puts keeping the value or lease of the key.
+func (s etcdKVServer) putKeeping(ctx context.Context, req *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	for range guardedAttempts {
+		prev, err := s.s.GetEntry(string(req.Key))
+		if err != nil {
+			return nil, etcdStatus(s.l, "etcd Put", err)
+		}
+
+		action := internalRaft.StoreAction{
+			Action:   internalRaft.Put,
+			Key:      prev.Key,
+			Value:    req.Value,
+			Lease:    req.Lease,
+			Revision: prev.ModRevision,
+		}
+		if req.IgnoreValue {
+			action.Value = prev.Value
+		}
+		if req.IgnoreLease {
+			action.Lease = prev.Lease
+		}
+
+		res, err := s.propose(ctx, action)
+		if errors.Is(err, store.PreconditionFailedError) {
+			continue
+		}
+		if err != nil {
+			return nil, etcdStatus(s.l, "etcd Put", err)
+		}
+
+		out := &etcdserverpb.PutResponse{Header: s.etcdHeader(res.Revision)}
+		if req.PrevKv {
+			out.PrevKv = newEtcdKeyValue(prev)
+		}
+
+		return out, nil
+	}
+
+	return nil, errTooManyConflicts
+}
+
+func (s *server) propose(ctx context.Context, action internalRaft.StoreAction) (internalRaft.ActionResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	return s.p.Propose(ctx, action)
+}
+
+// DeleteRange deletes in a single proposal, through a batch reading the
+// deleted entries when they are asked for.
+func (s etcdKVServer) DeleteRange(ctx context.Context, req *etcdserverpb.DeleteRangeRequest) (*etcdserverpb.DeleteRangeResponse, error) {
+	start, end, single := etcdRange(req.Key, req.RangeEnd)
+
+	switch {
+	case req.PrevKv:
+		res, err := s.propose(ctx, internalRaft.StoreAction{
+			Action: internalRaft.Batch,
+			Actions: []internalRaft.StoreAction{
+				{Action: internalRaft.DeleteRange, Key: start, RangeEnd: end},
+			},
+		})
+		if err != nil {
+			return nil, etcdStatus(s.l, "etcd DeleteRange", err)
+		}
+
+		return newDeleteRangeResponse(s.etcdHeader(res.Revision), res.Batch[0].Entries, true), nil
+	case single:
+		res, err := s.propose(ctx, internalRaft.StoreAction{
+			Action: internalRaft.Delete,
+			Key:    start,
+		})
+		switch {
+		case errors.Is(err, store.KeyNotFoundError):
+			return &etcdserverpb.DeleteRangeResponse{Header: s.etcdHeader(s.s.Revision())}, nil
+		case err != nil:
+			return nil, etcdStatus(s.l, "etcd DeleteRange", err)
+		}
+
+		return &etcdserverpb.DeleteRangeResponse{Header: s.etcdHeader(res.Revision), Deleted: 1}, nil
+	default:
+		res, err := s.propose(ctx, internalRaft.StoreAction{
+			Action:   internalRaft.DeleteRange,
+			Key:      start,
+			RangeEnd: end,
+		})
+		if err != nil {
+			return nil, etcdStatus(s.l, "etcd DeleteRange", err)
+		}
+
+		return &etcdserverpb.DeleteRangeResponse{Header: s.etcdHeader(res.Revision), Deleted: res.Deleted}, nil
+	}
+}
+
+func newDeleteRangeResponse(
+	header *etcdserverpb.ResponseHeader,
+	deleted []store.Entry,
+	prevKv bool,
+) *etcdserverpb.DeleteRangeResponse {
+	res := &etcdserverpb.DeleteRangeResponse{
+		Header:  header,
+		Deleted: int64(len(deleted)),
+	}
+	if prevKv {
+		res.PrevKvs = make([]*mvccpb.KeyValue, 0, len(deleted))
+		for _, e := range deleted {
+			res.PrevKvs = append(res.PrevKvs, newEtcdKeyValue(e))
+		}
+	}
+
+	return res
+}
+
+// Compact succeeds without effect for revisions up to the current one, the
+// store keeping no history to compact.
+func (s etcdKVServer) Compact(ctx context.Context, req *etcdserverpb.CompactionRequest) (*etcdserverpb.CompactionResponse, error) {
+	revision := s.s.Revision()
+	if req.Revision > revision {
+		return nil, rpctypes.ErrGRPCFutureRev
+	}
+
+	return &etcdserverpb.CompactionResponse{Header: s.etcdHeader(revision)}, nil
+}
@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type etcdLeaseServer struct {
+	etcdserverpb.UnimplementedLeaseServer
+	*server
+}
+
+// remainingTTL rounds the time left to the lease's deadline up to seconds.
+func remainingTTL(lease store.Lease) int64 {
+	left := time.Until(time.Unix(0, lease.ExpiresAt))
+	if left <= 0 {
+		return 0
+	}
+
+	return int64((left + time.Second - 1) / time.Second)
+}
+
+func (s etcdLeaseServer) LeaseGrant(ctx context.Context, req *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error) {
+	if req.ID != 0 {
+		return nil, status.Error(codes.Unimplemented, "choosing the lease ID is not supported")
+	}
+	if req.TTL <= 0 {
+		return nil, invalidArgument("TTL must be positive")
+	}
+
+	res, err := s.propose(ctx, internalRaft.StoreAction{
+		Action:    internalRaft.LeaseGrant,
+		TTL:       req.TTL,
+		ExpiresAt: time.Now().Add(time.Duration(req.TTL) * time.Second).UnixNano(),
+		Caller:    callerOf(ctx),
+	})
+	if err != nil {
+		return nil, etcdStatus(s.l, "etcd LeaseGrant", err)
+	}
+
+	return &etcdserverpb.LeaseGrantResponse{
+		Header: s.etcdHeader(res.Revision),
+		ID:     res.Lease,
+		TTL:    req.TTL,
+	}, nil
+}
+
+func (s etcdLeaseServer) LeaseRevoke(ctx context.Context, req *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error) {
+	res, err := s.propose(ctx, internalRaft.StoreAction{
+		Action: internalRaft.LeaseRevoke,
+		Lease:  req.ID,
+		Caller: callerOf(ctx),
+	})
+	if err != nil {
+		return nil, etcdStatus(s.l, "etcd LeaseRevoke", err)
+	}
+
+	return &etcdserverpb.LeaseRevokeResponse{Header: s.etcdHeader(res.Revision)}, nil
+}
+
+// LeaseKeepAlive answers keep-alives of unknown leases with a zero TTL, which
+// etcd clients take as the lease being gone.
+func (s etcdLeaseServer) LeaseKeepAlive(stream etcdserverpb.Lease_LeaseKeepAliveServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		res := &etcdserverpb.LeaseKeepAliveResponse{ID: req.ID}
+		lease, err := s.keepAlive(stream.Context(), req.ID)
+		switch {
+		case errors.Is(err, store.LeaseNotFoundError):
+		case err != nil:
+			return etcdStatus(s.l, "etcd LeaseKeepAlive", err)
+		default:
+			res.TTL = lease.TTL
+		}
+		res.Header = s.etcdHeader(s.s.Revision())
+
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+}
+
+// LeaseTimeToLive reports unknown leases with a TTL of -1, as etcd does.
+func (s etcdLeaseServer) LeaseTimeToLive(ctx context.Context, req *etcdserverpb.LeaseTimeToLiveRequest) (*etcdserverpb.LeaseTimeToLiveResponse, error) {
+	res := &etcdserverpb.LeaseTimeToLiveResponse{
+		Header: s.etcdHeader(s.s.Revision()),
+		ID:     req.ID,
+		TTL:    -1,
+	}
+
+	lease, err := s.s.GetLease(req.ID)
+	if err != nil {
+		return res, nil
+	}
+
+	res.TTL, res.GrantedTTL = remainingTTL(lease), lease.TTL
+	if req.Keys {
+		for _, key := range lease.KeyList() {
+			res.Keys = append(res.Keys, []byte(key))
+		}
+	}
+
+	return res, nil
+}
+
+func (s etcdLeaseServer) LeaseLeases(ctx context.Context, req *etcdserverpb.LeaseLeasesRequest) (*etcdserverpb.LeaseLeasesResponse, error) {
+	leases := s.s.Leases()
+
+	res := &etcdserverpb.LeaseLeasesResponse{
+		Header: s.etcdHeader(s.s.Revision()),
+		Leases: make([]*etcdserverpb.LeaseStatus, 0, len(leases)),
+	}
+	for _, lease := range leases {
+		res.Leases = append(res.Leases, &etcdserverpb.LeaseStatus{ID: lease.ID})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxTxnOps matches etcd's default limit of compares and operations per
+// branch of a txn.
+const maxTxnOps = 128
+
+// Txn evaluates the compares on the current state of the store and proposes
+// the chosen branch as a single batch, guarded so it only applies if the
+// compared keys are left untouched until then. Branches that only read are
+// served without proposing.
+func (s etcdKVServer) Txn(ctx context.Context, req *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	if err := checkTxn(req); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(req.Compare))
+	for _, c := range req.Compare {
+		keys = append(keys, string(c.Key))
+	}
+
+	for range guardedAttempts {
+		found, _, revision := s.s.GetMany(keys)
+		entries := make(map[string]store.Entry, len(found))
+		for _, e := range found {
+			entries[e.Key] = e
+		}
+
+		succeeded := true
+		for _, c := range req.Compare {
+			e, ok := entries[string(c.Key)]
+			if !compare(c, e, ok) {
+				succeeded = false
+				break
+			}
+		}
+
+		ops := req.Failure
+		if succeeded {
+			ops = req.Success
+		}
+
+		var res *etcdserverpb.TxnResponse
+		var err error
+		if readOnly(ops) {
+			res, err = s.readTxn(ops, revision)
+		} else {
+			res, err = s.writeTxn(ctx, ops, keys, entries, revision)
+		}
+		if errors.Is(err, store.PreconditionFailedError) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res.Succeeded = succeeded
+		return res, nil
+	}
+
+	return nil, errTooManyConflicts
+}
+
+// checkTxn rejects the txns etcd would reject, and those relying on what
+// this store does not support: range compares, nested txns and puts keeping
+// the value or lease of their key.
+func checkTxn(req *etcdserverpb.TxnRequest) error {
+	if len(req.Compare) > maxTxnOps || len(req.Success) > maxTxnOps || len(req.Failure) > maxTxnOps {
+		return rpctypes.ErrGRPCTooManyOps
+	}
+
+	for _, c := range req.Compare {
+		if len(c.RangeEnd) != 0 {
+			return status.Error(codes.Unimplemented, "range compares are not supported")
+		}
+	}
+
+	for _, ops := range [][]*etcdserverpb.RequestOp{req.Success, req.Failure} {
+		if err := checkTxnOps(ops); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkTxnOps(ops []*etcdserverpb.RequestOp) error {
+	puts := make(map[string]bool)
+	deletes := make([]*etcdserverpb.DeleteRangeRequest, 0)
+
+	for _, op := range ops {
+		switch r := op.Request.(type) {
+		case *etcdserverpb.RequestOp_RequestRange:
+		case *etcdserverpb.RequestOp_RequestPut:
+			put := r.RequestPut
+			switch {
+			case len(put.Key) == 0:
+				return rpctypes.ErrGRPCEmptyKey
+			case put.IgnoreValue || put.IgnoreLease:
+				return status.Error(codes.Unimplemented, "puts keeping the value or lease are not supported in txns")
+			case puts[string(put.Key)]:
+				return rpctypes.ErrGRPCDuplicateKey
+			}
+			puts[string(put.Key)] = true
+		case *etcdserverpb.RequestOp_RequestDeleteRange:
+			deletes = append(deletes, r.RequestDeleteRange)
+		case *etcdserverpb.RequestOp_RequestTxn:
+			return status.Error(codes.Unimplemented, "nested txns are not supported")
+		default:
+			return invalidArgument("unknown txn operation")
+		}
+	}
+
+	for _, d := range deletes {
+		start, end, _ := etcdRange(d.Key, d.RangeEnd)
+		for key := range puts {
+			if store.InRange(key, start, end) {
+				return rpctypes.ErrGRPCDuplicateKey
+			}
+		}
+	}
+
+	return nil
+}
+
+// compare evaluates c against e, found telling whether the key exists.
+// Missing keys compare as zero, except on their value which always fails.
+func compare(c *etcdserverpb.Compare, e store.Entry, found bool) bool {
+	var res int
+	switch c.Target {
+	case etcdserverpb.Compare_VERSION:
+		res = cmp.Compare(e.Version, c.GetVersion())
+	case etcdserverpb.Compare_CREATE:
+		res = cmp.Compare(e.CreateRevision, c.GetCreateRevision())
+	case etcdserverpb.Compare_MOD:
+		res = cmp.Compare(e.ModRevision, c.GetModRevision())
+	case etcdserverpb.Compare_VALUE:
+		if !found {
+			return false
+		}
+		res = bytes.Compare(e.Value, c.GetValue())
+	case etcdserverpb.Compare_LEASE:
+		res = cmp.Compare(e.Lease, c.GetLease())
+	default:
+		return false
+	}
+
+	switch c.Result {
+	case etcdserverpb.Compare_EQUAL:
+		return res == 0
+	case etcdserverpb.Compare_NOT_EQUAL:
+		return res != 0
+	case etcdserverpb.Compare_GREATER:
+		return res > 0
+	case etcdserverpb.Compare_LESS:
+		return res < 0
+	default:
+		return false
+	}
+}
+
+func readOnly(ops []*etcdserverpb.RequestOp) bool {
+	for _, op := range ops {
+		if _, ok := op.Request.(*etcdserverpb.RequestOp_RequestRange); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readTxn serves ranges read at the revision the compares were evaluated
+// at, failing the precondition when a write got in between.
+func (s *server) readTxn(ops []*etcdserverpb.RequestOp, revision int64) (*etcdserverpb.TxnResponse, error) {
+	res := &etcdserverpb.TxnResponse{
+		Header:    s.etcdHeader(revision),
+		Responses: make([]*etcdserverpb.ResponseOp, 0, len(ops)),
+	}
+
+	for _, op := range ops {
+		r, err := s.etcdRange(op.GetRequestRange())
+		if err != nil {
+			return nil, err
+		}
+		if r.Header.Revision != revision {
+			return nil, store.PreconditionFailedError
+		}
+
+		res.Responses = append(res.Responses, &etcdserverpb.ResponseOp{
+			Response: &etcdserverpb.ResponseOp_ResponseRange{ResponseRange: r},
+		})
+	}
+
+	return res, nil
+}
+
+// writeTxn proposes ops as a batch behind checks that the compared keys are
+// still as entries holds them.
+func (s *server) writeTxn(
+	ctx context.Context,
+	ops []*etcdserverpb.RequestOp,
+	keys []string,
+	entries map[string]store.Entry,
+	revision int64,
+) (*etcdserverpb.TxnResponse, error) {
+	actions := make([]internalRaft.StoreAction, 0, len(keys)+2*len(ops))
+	for _, key := range keys {
+		guard := internalRaft.StoreAction{Action: internalRaft.Get, Key: key}
+		if e, ok := entries[key]; ok {
+			guard.Revision = e.ModRevision
+		} else {
+			guard.Existence = store.MustNotExist
+		}
+		actions = append(actions, guard)
+	}
+
+	// results indexes, for every op, the batch result its response reads.
+	results := make([]int, 0, len(ops))
+	for _, op := range ops {
+		switch r := op.Request.(type) {
+		case *etcdserverpb.RequestOp_RequestRange:
+			if err := checkRevision(r.RequestRange.Revision, revision); err != nil {
+				return nil, err
+			}
+			start, end, _ := etcdRange(r.RequestRange.Key, r.RequestRange.RangeEnd)
+			results = append(results, len(actions))
+			actions = append(actions, internalRaft.StoreAction{Action: internalRaft.Range, Key: start, RangeEnd: end})
+		case *etcdserverpb.RequestOp_RequestPut:
+			put := r.RequestPut
+			results = append(results, len(actions))
+			actions = append(actions,
+				internalRaft.StoreAction{Action: internalRaft.Range, Key: string(put.Key), RangeEnd: singleKeyEnd(string(put.Key))},
+				internalRaft.StoreAction{Action: internalRaft.Put, Key: string(put.Key), Value: put.Value, Lease: put.Lease},
+			)
+		case *etcdserverpb.RequestOp_RequestDeleteRange:
+			start, end, _ := etcdRange(r.RequestDeleteRange.Key, r.RequestDeleteRange.RangeEnd)
+			results = append(results, len(actions))
+			actions = append(actions, internalRaft.StoreAction{Action: internalRaft.DeleteRange, Key: start, RangeEnd: end})
+		}
+	}
+
+	out, err := s.propose(ctx, internalRaft.StoreAction{
+		Action:  internalRaft.Batch,
+		Actions: actions,
+	})
+	switch {
+	case errors.Is(err, store.PreconditionFailedError):
+		return nil, err
+	case err != nil:
+		return nil, etcdStatus(s.l, "etcd Txn", err)
+	}
+
+	header := s.etcdHeader(out.Revision)
+	res := &etcdserverpb.TxnResponse{
+		Header:    header,
+		Responses: make([]*etcdserverpb.ResponseOp, 0, len(ops)),
+	}
+	for i, op := range ops {
+		read := out.Batch[results[i]].Entries
+
+		var resOp etcdserverpb.ResponseOp
+		switch r := op.Request.(type) {
+		case *etcdserverpb.RequestOp_RequestRange:
+			rangeRes, err := s.rangeResponse(r.RequestRange, read, out.Revision)
+			if err != nil {
+				return nil, err
+			}
+			resOp.Response = &etcdserverpb.ResponseOp_ResponseRange{ResponseRange: rangeRes}
+		case *etcdserverpb.RequestOp_RequestPut:
+			putRes := &etcdserverpb.PutResponse{Header: header}
+			if r.RequestPut.PrevKv && len(read) != 0 {
+				putRes.PrevKv = newEtcdKeyValue(read[0])
+			}
+			resOp.Response = &etcdserverpb.ResponseOp_ResponsePut{ResponsePut: putRes}
+		case *etcdserverpb.RequestOp_RequestDeleteRange:
+			resOp.Response = &etcdserverpb.ResponseOp_ResponseDeleteRange{
+				ResponseDeleteRange: newDeleteRangeResponse(header, read, r.RequestDeleteRange.PrevKv),
+			}
+		}
+		res.Responses = append(res.Responses, &resOp)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,268 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// progressWatchID marks the responses to progress requests, which concern
+// every watch of the stream.
+const progressWatchID = -1
+
+var errWatchIDInUse = errors.New("watch ID already in use")
+
+type etcdWatchServer struct {
+	etcdserverpb.UnimplementedWatchServer
+	*server
+}
+
+// watchStream holds the watches created over one Watch call. Every response
+// goes through out so a single goroutine sends on the stream.
+type watchStream struct {
+	*server
+	ctx     context.Context
+	out     chan *etcdserverpb.WatchResponse
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	nextID  int64
+	watches map[int64]chan struct{}
+}
+
+func (s etcdWatchServer) Watch(stream etcdserverpb.Watch_WatchServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	ws := &watchStream{
+		server:  s.server,
+		ctx:     ctx,
+		out:     make(chan *etcdserverpb.WatchResponse),
+		watches: make(map[int64]chan struct{}),
+	}
+	defer ws.wg.Wait()
+
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case res := <-ws.out:
+				if err := stream.Send(res); err != nil {
+					s.l.Debug("etcd watch client gone", "err", err)
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		switch r := req.RequestUnion.(type) {
+		case *etcdserverpb.WatchRequest_CreateRequest:
+			ws.create(r.CreateRequest)
+		case *etcdserverpb.WatchRequest_CancelRequest:
+			ws.cancel(r.CancelRequest.WatchId)
+		case *etcdserverpb.WatchRequest_ProgressRequest:
+			ws.send(&etcdserverpb.WatchResponse{
+				Header:  s.etcdHeader(s.s.Revision()),
+				WatchId: progressWatchID,
+			})
+		}
+	}
+}
+
+// send queues res for the stream, giving up once the stream is done.
+func (ws *watchStream) send(res *etcdserverpb.WatchResponse) bool {
+	select {
+	case ws.out <- res:
+		return true
+	case <-ws.ctx.Done():
+		return false
+	}
+}
+
+// register reserves the requested watch ID, or the lowest free one when
+// none is requested.
+func (ws *watchStream) register(id int64) (int64, chan struct{}, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if id == 0 {
+		for ws.watches[ws.nextID] != nil {
+			ws.nextID++
+		}
+		id = ws.nextID
+	} else if ws.watches[id] != nil {
+		return id, nil, errWatchIDInUse
+	}
+
+	stop := make(chan struct{})
+	ws.watches[id] = stop
+
+	return id, stop, nil
+}
+
+func (ws *watchStream) unregister(id int64) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	delete(ws.watches, id)
+}
+
+func (ws *watchStream) cancel(id int64) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if stop, ok := ws.watches[id]; ok {
+		close(stop)
+		delete(ws.watches, id)
+	}
+}
+
+// create starts a watch and confirms it before any of its events. Ranges
+// the store cannot watch directly are watched whole and filtered.
+func (ws *watchStream) create(req *etcdserverpb.WatchCreateRequest) {
+	id, stop, err := ws.register(req.WatchId)
+	if err != nil {
+		ws.send(&etcdserverpb.WatchResponse{
+			Header:       ws.etcdHeader(ws.s.Revision()),
+			WatchId:      id,
+			Created:      true,
+			Canceled:     true,
+			CancelReason: err.Error(),
+		})
+		return
+	}
+
+	start, end, single := etcdRange(req.Key, req.RangeEnd)
+	key, prefix := start, !single
+	if prefix && end != store.PrefixEnd(start) {
+		key = ""
+	}
+
+	watcher, err := ws.s.Watch(key, prefix, req.StartRevision)
+	revision := ws.s.Revision()
+	created := &etcdserverpb.WatchResponse{
+		Header:  ws.etcdHeader(revision),
+		WatchId: id,
+		Created: true,
+	}
+	if err != nil {
+		ws.unregister(id)
+		ws.send(created)
+		// The store keeps no older history, so the oldest revision still
+		// watchable is the next one.
+		ws.send(&etcdserverpb.WatchResponse{
+			Header:          ws.etcdHeader(revision),
+			WatchId:         id,
+			Canceled:        true,
+			CompactRevision: revision + 1,
+			CancelReason:    err.Error(),
+		})
+		return
+	}
+	if !ws.send(created) {
+		watcher.Cancel()
+		return
+	}
+
+	ws.wg.Add(1)
+	go func() {
+		defer ws.wg.Done()
+		defer watcher.Cancel()
+		ws.serve(id, req, start, end, watcher, stop)
+	}()
+}
+
+// serve forwards the events of watcher to the stream until the watch is
+// cancelled, by the client or by the store.
+func (ws *watchStream) serve(
+	id int64,
+	req *etcdserverpb.WatchCreateRequest,
+	start, end string,
+	watcher *store.Watcher,
+	stop chan struct{},
+) {
+	noPut := slices.Contains(req.Filters, etcdserverpb.WatchCreateRequest_NOPUT)
+	noDelete := slices.Contains(req.Filters, etcdserverpb.WatchCreateRequest_NODELETE)
+
+	var progressC <-chan time.Time
+	if req.ProgressNotify {
+		ticker := time.NewTicker(watchProgressInterval)
+		defer ticker.Stop()
+		progressC = ticker.C
+	}
+
+	for {
+		select {
+		case e, ok := <-watcher.Events():
+			if !ok {
+				ws.unregister(id)
+				ws.send(&etcdserverpb.WatchResponse{
+					Header:       ws.etcdHeader(ws.s.Revision()),
+					WatchId:      id,
+					Canceled:     true,
+					CancelReason: watcher.Err().Error(),
+				})
+				return
+			}
+
+			res := &etcdserverpb.WatchResponse{
+				Header:  ws.etcdHeader(e.Entry.ModRevision),
+				WatchId: id,
+			}
+			switch {
+			case e.Type == store.ProgressEvent:
+			case e.Entry.ModRevision < req.StartRevision,
+				!store.InRange(e.Entry.Key, start, end),
+				e.Type == store.PutEvent && noPut,
+				e.Type == store.DeleteEvent && noDelete:
+				continue
+			default:
+				res.Events = []*mvccpb.Event{newEtcdEvent(e, req.PrevKv)}
+			}
+
+			if !ws.send(res) {
+				return
+			}
+		case <-progressC:
+			ws.s.RequestProgress(watcher)
+		case <-stop:
+			ws.send(&etcdserverpb.WatchResponse{
+				Header:   ws.etcdHeader(ws.s.Revision()),
+				WatchId:  id,
+				Canceled: true,
+			})
+			return
+		case <-ws.ctx.Done():
+			return
+		}
+	}
+}
+
+func newEtcdEvent(e store.Event, prevKv bool) *mvccpb.Event {
+	res := &mvccpb.Event{Kv: newEtcdKeyValue(e.Entry)}
+	if e.Type == store.DeleteEvent {
+		res.Type = mvccpb.DELETE
+	}
+	if prevKv && e.PrevEntry != nil {
+		res.PrevKv = newEtcdKeyValue(*e.PrevEntry)
+	}
+
+	return res
+}
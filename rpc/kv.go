@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/rpc/pb"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+type kvServer struct {
+	pb.UnimplementedKVServer
+	*server
+}
+
+func newKeyValue(e store.Entry) *pb.KeyValue {
+	return &pb.KeyValue{
+		Key:            e.Key,
+		Value:          e.Value,
+		CreateRevision: e.CreateRevision,
+		ModRevision:    e.ModRevision,
+		Version:        e.Version,
+		Lease:          e.Lease,
+	}
+}
+
+func (s kvServer) Put(ctx context.Context, req *pb.PutRequest) (*pb.PutResponse, error) {
+	if req.TtlSeconds < 0 {
+		return nil, invalidArgument("ttl_seconds must not be negative")
+	}
+
+	action := internalRaft.StoreAction{
+		Action: internalRaft.Put,
+		Key:    req.Key,
+		Value:  req.Value,
+		Lease:  req.Lease,
+	}
+	if req.TtlSeconds > 0 {
+		action.ExpiresAt = time.Now().Add(time.Duration(req.TtlSeconds) * time.Second).UnixNano()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	res, err := s.p.Propose(ctx, action)
+	if err != nil {
+		return nil, toStatus(s.l, "Put", err)
+	}
+
+	return &pb.PutResponse{Revision: res.Revision}, nil
+}
+
+func (s kvServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	found, _, revision := s.s.GetMany([]string{req.Key})
+	if len(found) == 0 {
+		return nil, toStatus(s.l, "Get", store.KeyNotFoundError)
+	}
+
+	return &pb.GetResponse{Kv: newKeyValue(found[0]), Revision: revision}, nil
+}
+
+func (s kvServer) Range(ctx context.Context, req *pb.RangeRequest) (*pb.RangeResponse, error) {
+	start, end, err := keyRange(req.Prefix, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit < 0 {
+		return nil, invalidArgument("limit must not be negative")
+	}
+
+	entries, revision, more := s.s.Range(start, end, int(req.Limit))
+
+	res := &pb.RangeResponse{
+		Kvs:      make([]*pb.KeyValue, 0, len(entries)),
+		Revision: revision,
+		More:     more,
+	}
+	for _, e := range entries {
+		res.Kvs = append(res.Kvs, newKeyValue(e))
+	}
+
+	return res, nil
+}
+
+func (s kvServer) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	res, err := s.p.Propose(ctx, internalRaft.StoreAction{
+		Action: internalRaft.Delete,
+		Key:    req.Key,
+	})
+	if err != nil {
+		return nil, toStatus(s.l, "Delete", err)
+	}
+
+	return &pb.DeleteResponse{Revision: res.Revision}, nil
+}
+
+func (s kvServer) DeleteRange(ctx context.Context, req *pb.DeleteRangeRequest) (*pb.DeleteRangeResponse, error) {
+	if req.Prefix == "" && req.Start == "" {
+		return nil, invalidArgument("one of prefix or start is required")
+	}
+
+	start, end, err := keyRange(req.Prefix, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	res, err := s.p.Propose(ctx, internalRaft.StoreAction{
+		Action:   internalRaft.DeleteRange,
+		Key:      start,
+		RangeEnd: end,
+	})
+	if err != nil {
+		return nil, toStatus(s.l, "DeleteRange", err)
+	}
+
+	return &pb.DeleteRangeResponse{Deleted: res.Deleted, Revision: res.Revision}, nil
+}
+
+func (s kvServer) Increment(ctx context.Context, req *pb.IncrementRequest) (*pb.IncrementResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	res, err := s.p.Propose(ctx, internalRaft.StoreAction{
+		Action: internalRaft.Increment,
+		Key:    req.Key,
+		Delta:  req.Delta,
+	})
+	if err != nil {
+		return nil, toStatus(s.l, "Increment", err)
+	}
+
+	return &pb.IncrementResponse{Value: res.Counter, Revision: res.Revision}, nil
+}
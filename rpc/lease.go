@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/rpc/pb"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+type leaseServer struct {
+	pb.UnimplementedLeaseServer
+	*server
+}
+
+func newLeaseInfo(lease store.Lease) *pb.LeaseInfo {
+	return &pb.LeaseInfo{
+		Id:         lease.ID,
+		TtlSeconds: lease.TTL,
+		ExpiresAt:  lease.ExpiresAt,
+		Keys:       lease.KeyList(),
+	}
+}
+
+func (s leaseServer) LeaseGrant(ctx context.Context, req *pb.LeaseGrantRequest) (*pb.LeaseInfo, error) {
+	if req.TtlSeconds <= 0 {
+		return nil, invalidArgument("ttl_seconds must be positive")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	res, err := s.p.Propose(ctx, internalRaft.StoreAction{
+		Action:    internalRaft.LeaseGrant,
+		TTL:       req.TtlSeconds,
+		ExpiresAt: time.Now().Add(time.Duration(req.TtlSeconds) * time.Second).UnixNano(),
+		Caller:    callerOf(ctx),
+	})
+	if err != nil {
+		return nil, toStatus(s.l, "LeaseGrant", err)
+	}
+
+	lease, err := s.s.GetLease(res.Lease)
+	if err != nil {
+		return nil, toStatus(s.l, "LeaseGrant", err)
+	}
+
+	return newLeaseInfo(lease), nil
+}
+
+func (s leaseServer) LeaseGet(ctx context.Context, req *pb.LeaseGetRequest) (*pb.LeaseInfo, error) {
+	lease, err := s.s.GetLease(req.Id)
+	if err != nil {
+		return nil, toStatus(s.l, "LeaseGet", err)
+	}
+
+	return newLeaseInfo(lease), nil
+}
+
+func (s leaseServer) LeaseRevoke(ctx context.Context, req *pb.LeaseRevokeRequest) (*pb.LeaseRevokeResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	res, err := s.p.Propose(ctx, internalRaft.StoreAction{
+		Action: internalRaft.LeaseRevoke,
+		Lease:  req.Id,
+		Caller: callerOf(ctx),
+	})
+	if err != nil {
+		return nil, toStatus(s.l, "LeaseRevoke", err)
+	}
+
+	return &pb.LeaseRevokeResponse{Id: req.Id, Deleted: res.Deleted}, nil
+}
+
+func (s leaseServer) LeaseKeepAlive(stream pb.Lease_LeaseKeepAliveServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		lease, err := s.keepAlive(stream.Context(), req.Id)
+		switch {
+		case errors.Is(err, store.LeaseNotFoundError):
+			err = stream.Send(&pb.LeaseInfo{Id: req.Id, TtlSeconds: -1})
+		case err != nil:
+			return toStatus(s.l, "LeaseKeepAlive", err)
+		default:
+			err = stream.Send(newLeaseInfo(lease))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// keepAlive pushes the lease's deadline back by its TTL.
+func (s *server) keepAlive(ctx context.Context, ID int64) (store.Lease, error) {
+	lease, err := s.s.GetLease(ID)
+	if err != nil {
+		return store.Lease{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proposalTimeout)
+	defer cancel()
+
+	if _, err := s.p.Propose(ctx, internalRaft.StoreAction{
+		Action:    internalRaft.LeaseKeepAlive,
+		Lease:     ID,
+		ExpiresAt: time.Now().Add(time.Duration(lease.TTL) * time.Second).UnixNano(),
+		Caller:    callerOf(ctx),
+	}); err != nil {
+		return store.Lease{}, err
+	}
+
+	return s.s.GetLease(ID)
+}
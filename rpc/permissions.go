@@ -0,0 +1,134 @@
+package rpc
+
+import (
+	"github.com/pablovarg/distributed-key-value-store/rpc/pb"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+var adminAccess = []store.Access{{Admin: true}}
+
+// requestAccess lists what req touches. An empty list only needs the caller
+// to be authenticated, and requests it does not know need the root role.
+// Malformed ranges are left for the handlers to reject.
+func requestAccess(s *store.Store, req any) []store.Access {
+	switch r := req.(type) {
+	case *pb.PutRequest:
+		return append([]store.Access{store.KeyAccess(r.Key, true)}, attachAccess(s, r.Lease)...)
+	case *pb.GetRequest:
+		return []store.Access{store.KeyAccess(r.Key, false)}
+	case *pb.RangeRequest:
+		return rangeAccess(r.Prefix, r.Start, r.End, false)
+	case *pb.DeleteRequest:
+		return []store.Access{store.KeyAccess(r.Key, true)}
+	case *pb.DeleteRangeRequest:
+		return rangeAccess(r.Prefix, r.Start, r.End, true)
+	case *pb.IncrementRequest:
+		return []store.Access{store.KeyAccess(r.Key, true)}
+	case *pb.WatchRequest:
+		if r.Prefix {
+			return []store.Access{store.PrefixAccess(r.Key, false)}
+		}
+		return []store.Access{store.KeyAccess(r.Key, false)}
+	case *pb.LeaseGetRequest:
+		return leaseAccess(s, r.Id)
+	case *pb.LeaseKeepAliveRequest:
+		return leaseAccess(s, r.Id)
+	case *pb.LeaseRevokeRequest:
+		return leaseAccess(s, r.Id)
+	case *pb.LeaseGrantRequest, *pb.StatusRequest, *pb.MemberListRequest:
+		return nil
+
+	case *etcdserverpb.RangeRequest:
+		return []store.Access{etcdAccess(r.Key, r.RangeEnd, false)}
+	case *etcdserverpb.PutRequest:
+		return append([]store.Access{store.KeyAccess(string(r.Key), true)}, attachAccess(s, r.Lease)...)
+	case *etcdserverpb.DeleteRangeRequest:
+		return []store.Access{etcdAccess(r.Key, r.RangeEnd, true)}
+	case *etcdserverpb.TxnRequest:
+		return txnAccess(s, r)
+	case *etcdserverpb.WatchRequest:
+		if c := r.GetCreateRequest(); c != nil {
+			return []store.Access{etcdAccess(c.Key, c.RangeEnd, false)}
+		}
+		return nil
+	case *etcdserverpb.LeaseRevokeRequest:
+		return leaseAccess(s, r.ID)
+	case *etcdserverpb.LeaseKeepAliveRequest:
+		return leaseAccess(s, r.ID)
+	case *etcdserverpb.LeaseTimeToLiveRequest:
+		return leaseAccess(s, r.ID)
+	case *etcdserverpb.LeaseGrantRequest, *etcdserverpb.LeaseLeasesRequest:
+		return nil
+	default:
+		return adminAccess
+	}
+}
+
+func rangeAccess(prefix, start, end string, write bool) []store.Access {
+	start, end, err := keyRange(prefix, start, end)
+	if err != nil {
+		return nil
+	}
+
+	return []store.Access{{Start: start, End: end, Write: write}}
+}
+
+func etcdAccess(key, rangeEnd []byte, write bool) store.Access {
+	start, end, _ := etcdRange(key, rangeEnd)
+	return store.Access{Start: start, End: end, Write: write}
+}
+
+// txnAccess covers the compared keys and the operations of both branches,
+// whichever ends up applied.
+func txnAccess(s *store.Store, r *etcdserverpb.TxnRequest) []store.Access {
+	res := make([]store.Access, 0, len(r.Compare)+len(r.Success)+len(r.Failure))
+	for _, c := range r.Compare {
+		res = append(res, etcdAccess(c.Key, c.RangeEnd, false))
+	}
+
+	for _, ops := range [][]*etcdserverpb.RequestOp{r.Success, r.Failure} {
+		for _, op := range ops {
+			switch op := op.Request.(type) {
+			case *etcdserverpb.RequestOp_RequestRange:
+				res = append(res, etcdAccess(op.RequestRange.Key, op.RequestRange.RangeEnd, false))
+			case *etcdserverpb.RequestOp_RequestPut:
+				res = append(res, store.KeyAccess(string(op.RequestPut.Key), true))
+				res = append(res, attachAccess(s, op.RequestPut.Lease)...)
+			case *etcdserverpb.RequestOp_RequestDeleteRange:
+				res = append(res, etcdAccess(op.RequestDeleteRange.Key, op.RequestDeleteRange.RangeEnd, true))
+			case *etcdserverpb.RequestOp_RequestTxn:
+				res = append(res, txnAccess(s, op.RequestTxn)...)
+			}
+		}
+	}
+
+	return res
+}
+
+// leaseAccess covers the use of a lease. Unknown leases are left for the
+// handlers to report, the owner being checked again as a proposal applies.
+func leaseAccess(s *store.Store, ID int64) []store.Access {
+	lease, err := s.GetLease(ID)
+	if err != nil {
+		return nil
+	}
+
+	return []store.Access{lease.UseAccess()}
+}
+
+// attachAccess covers the lease a put attaches its key to. Unknown leases are
+// only usable by root, so one granted to someone else in the meantime is
+// never attached to.
+func attachAccess(s *store.Store, ID int64) []store.Access {
+	if ID == 0 {
+		return nil
+	}
+
+	lease, err := s.GetLease(ID)
+	if err != nil {
+		lease = store.Lease{ID: ID}
+	}
+
+	return []store.Access{lease.UseAccess()}
+}
@@ -0,0 +1,382 @@
+// Package rpc serves the gRPC API, along with the subset of etcd's v3 API the
+// store can support. It proposes through the same raft path and reads the
+// same store as the HTTP API.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	internalRaft "github.com/pablovarg/distributed-key-value-store/raft"
+	"github.com/pablovarg/distributed-key-value-store/ratelimit"
+	"github.com/pablovarg/distributed-key-value-store/rpc/pb"
+	"github.com/pablovarg/distributed-key-value-store/store"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	"go.etcd.io/raft/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const proposalTimeout = 5 * time.Second
+
+// leaderMetadataKey reports the leader's ID to clients that reached a
+// follower, so they can find the leader's gRPC endpoint.
+const leaderMetadataKey = "x-leader-id"
+
+// authMetadataKey carries the bearer token once auth is enabled.
+const authMetadataKey = "authorization"
+
+// followerMethods may be served by any member, every other method needs the
+// leader, as with the HTTP API.
+var followerMethods = map[string]bool{
+	pb.Watch_Watch_FullMethodName:        true,
+	pb.Cluster_Status_FullMethodName:     true,
+	pb.Cluster_MemberList_FullMethodName: true,
+
+	etcdWatchMethod: true,
+}
+
+const (
+	etcdWatchMethod = "/etcdserverpb.Watch/Watch"
+	etcdRangeMethod = "/etcdserverpb.KV/Range"
+)
+
+type server struct {
+	l       *slog.Logger
+	n       raft.Node
+	p       internalRaft.Proposer
+	c       internalRaft.Cluster
+	s       *store.Store
+	members *internalRaft.Members
+}
+
+func NewGRPCServer(
+	l *slog.Logger,
+	n raft.Node,
+	p internalRaft.Proposer,
+	c internalRaft.Cluster,
+	s *store.Store,
+	members *internalRaft.Members,
+	limiter *ratelimit.Limiter,
+) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			unaryLoggingInterceptor(l),
+			unaryRateLimitInterceptor(l, s, limiter),
+			unaryAuthInterceptor(s),
+			unaryLeaderInterceptor(n),
+		),
+		grpc.ChainStreamInterceptor(
+			streamLoggingInterceptor(l),
+			streamRateLimitInterceptor(l, s, limiter),
+			streamAuthInterceptor(s),
+			streamLeaderInterceptor(n),
+		),
+	)
+
+	impl := &server{l: l, n: n, p: p, c: c, s: s, members: members}
+	pb.RegisterKVServer(srv, kvServer{server: impl})
+	pb.RegisterWatchServer(srv, watchServer{server: impl})
+	pb.RegisterLeaseServer(srv, leaseServer{server: impl})
+	pb.RegisterClusterServer(srv, clusterServer{server: impl})
+
+	etcdserverpb.RegisterKVServer(srv, etcdKVServer{server: impl})
+	etcdserverpb.RegisterWatchServer(srv, etcdWatchServer{server: impl})
+	etcdserverpb.RegisterLeaseServer(srv, etcdLeaseServer{server: impl})
+
+	return srv
+}
+
+func unaryLoggingInterceptor(l *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		l.Info("grpc hit", "method", info.FullMethod)
+		res, err := handler(ctx, req)
+		l.Info("grpc response", "method", info.FullMethod, "code", status.Code(err))
+
+		return res, err
+	}
+}
+
+func streamLoggingInterceptor(l *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		l.Info("grpc hit", "method", info.FullMethod)
+		err := handler(srv, ss)
+		l.Info("grpc response", "method", info.FullMethod, "code", status.Code(err))
+
+		return err
+	}
+}
+
+// unaryRateLimitInterceptor holds callers to the rate limit of the method
+// they call, shared with the HTTP and Redis servers.
+func unaryRateLimitInterceptor(l *slog.Logger, s *store.Store, limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkRateLimit(ctx, l, s, limiter, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// streamRateLimitInterceptor takes a single token for a whole stream, as
+// the HTTP API does for a watch.
+func streamRateLimitInterceptor(l *slog.Logger, s *store.Store, limiter *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkRateLimit(ss.Context(), l, s, limiter, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// checkRateLimit takes a token for the caller, told apart by the user of its
+// token while auth is enabled and by its IP otherwise. etcd methods fail with
+// etcd's own error, which its clients know to retry.
+func checkRateLimit(ctx context.Context, l *slog.Logger, s *store.Store, limiter *ratelimit.Limiter, method string) error {
+	if limiter.Limit(method).Unlimited() {
+		return nil
+	}
+
+	client := "ip:" + peerIP(ctx)
+	if principal, err := checkAuth(ctx, s); err == nil && principal.User != "" {
+		client = "user:" + principal.User
+	}
+
+	if ok, _ := limiter.Take(method, client); ok {
+		return nil
+	}
+
+	l.Info("grpc rate limited", "method", method, "client", client)
+	if strings.HasPrefix(method, "/etcdserverpb.") {
+		return rpctypes.ErrGRPCRequestTooManyRequests
+	}
+	return status.Errorf(codes.ResourceExhausted, "rate limit of %g calls a second exceeded", limiter.Limit(method).Rate)
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+func unaryAuthInterceptor(s *store.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		principal, err := checkAuth(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAccess(s, principal, req); err != nil {
+			return nil, err
+		}
+
+		return handler(context.WithValue(ctx, callerKey{}, principal.User), req)
+	}
+}
+
+func streamAuthInterceptor(s *store.Store) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		principal, err := checkAuth(ss.Context(), s)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, authorizedStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), callerKey{}, principal.User),
+			s:            s,
+			principal:    principal,
+		})
+	}
+}
+
+// authorizedStream checks every message received on a stream against the
+// permissions of its caller.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	s         *store.Store
+	principal store.Principal
+}
+
+func (as authorizedStream) Context() context.Context {
+	return as.ctx
+}
+
+func (as authorizedStream) RecvMsg(m any) error {
+	if err := as.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	return checkAccess(as.s, as.principal, m)
+}
+
+type callerKey struct{}
+
+// callerOf names the user the call was authenticated as, empty when auth is
+// off.
+func callerOf(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// checkAuth finds the principal of the bearer token in the authorization
+// metadata while auth is enabled, and a root one otherwise.
+func checkAuth(ctx context.Context, s *store.Store) (store.Principal, error) {
+	if !s.AuthEnabled() {
+		return store.Principal{Root: true}, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authMetadataKey)
+	if len(values) == 0 {
+		return store.Principal{}, status.Error(codes.Unauthenticated, "a bearer token is required")
+	}
+
+	token, _ := strings.CutPrefix(values[0], "Bearer ")
+	id, secret, _ := strings.Cut(token, ".")
+	principal, err := s.Authenticate(id, secret, time.Now().UnixNano())
+	if err != nil {
+		return store.Principal{}, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return principal, nil
+}
+
+// checkAccess requires the principal's roles to grant the keys, or the
+// administration, req needs.
+func checkAccess(s *store.Store, principal store.Principal, req any) error {
+	if principal.Root {
+		return nil
+	}
+
+	for _, a := range requestAccess(s, req) {
+		if !principal.Permits("", a) {
+			return status.Errorf(codes.PermissionDenied, "user %q may not %s", principal.User, a)
+		}
+	}
+
+	return nil
+}
+
+func unaryLeaderInterceptor(n raft.Node) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if serializable(info.FullMethod, req) {
+			return handler(ctx, req)
+		}
+
+		if err := checkLeader(n, info.FullMethod, func(md metadata.MD) { grpc.SetTrailer(ctx, md) }); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func streamLeaderInterceptor(n raft.Node) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkLeader(n, info.FullMethod, ss.SetTrailer); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// serializable reports whether req is an etcd range that accepts possibly
+// stale results, which any member may serve.
+func serializable(method string, req any) bool {
+	r, ok := req.(*etcdserverpb.RangeRequest)
+	return ok && method == etcdRangeMethod && r.Serializable
+}
+
+// checkLeader rejects calls to leader methods received by a follower with
+// UNAVAILABLE, reporting the known leader through the trailer.
+func checkLeader(n raft.Node, method string, setTrailer func(metadata.MD)) error {
+	if followerMethods[method] || internalRaft.IsLeader(n) {
+		return nil
+	}
+
+	lead := n.Status().Lead
+	if lead == raft.None {
+		return status.Error(codes.Unavailable, "no leader elected")
+	}
+
+	setTrailer(metadata.Pairs(leaderMetadataKey, strconv.FormatUint(lead, 10)))
+	return status.Errorf(codes.Unavailable, "not the leader, member %d is", lead)
+}
+
+// toStatus maps the errors of the store and the raft node to gRPC statuses.
+func toStatus(l *slog.Logger, method string, err error) error {
+	switch {
+	case errors.Is(err, store.KeyNotFoundError),
+		errors.Is(err, store.LeaseNotFoundError),
+		errors.Is(err, internalRaft.MemberNotFoundError):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.PreconditionFailedError),
+		errors.Is(err, store.NotIntegerError),
+		errors.Is(err, store.OverflowError):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, internalRaft.ValueTooLargeError):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, store.NoSpaceError):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, internalRaft.OverloadedError),
+		errors.Is(err, store.UploadsFullError),
+		errors.Is(err, store.ChunksMissingError):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, store.PermissionDeniedError):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, internalRaft.MemberExistsError):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, store.RevisionCompactedError):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, store.WatcherTooSlowError):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	default:
+		l.Error("grpc internal error", "method", method, "error", err.Error())
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func invalidArgument(format string, args ...any) error {
+	return status.Errorf(codes.InvalidArgument, format, args...)
+}
+
+// keyRange resolves the prefix or [start, end) of a request into a range.
+func keyRange(prefix, start, end string) (string, string, error) {
+	if prefix != "" {
+		if start != "" || end != "" {
+			return "", "", invalidArgument("prefix and start/end are mutually exclusive")
+		}
+		return prefix, store.PrefixEnd(prefix), nil
+	}
+
+	if end != "" && end <= start {
+		return "", "", invalidArgument("end must sort after start")
+	}
+
+	return start, end, nil
+}
@@ -0,0 +1,73 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/pablovarg/distributed-key-value-store/rpc/pb"
+	"github.com/pablovarg/distributed-key-value-store/store"
+)
+
+const watchProgressInterval = 10 * time.Second
+
+type watchServer struct {
+	pb.UnimplementedWatchServer
+	*server
+}
+
+func newWatchResponse(e store.Event) *pb.WatchResponse {
+	res := &pb.WatchResponse{Revision: e.Entry.ModRevision}
+
+	switch e.Type {
+	case store.PutEvent:
+		res.Type = pb.WatchResponse_PUT
+		res.Kv = newKeyValue(e.Entry)
+	case store.DeleteEvent:
+		res.Type = pb.WatchResponse_DELETE
+		res.Kv = newKeyValue(e.Entry)
+	case store.ProgressEvent:
+		res.Type = pb.WatchResponse_PROGRESS
+	}
+
+	return res
+}
+
+func (s watchServer) Watch(req *pb.WatchRequest, stream pb.Watch_WatchServer) error {
+	if req.StartRevision < 0 {
+		return invalidArgument("start_revision must not be negative")
+	}
+
+	watcher, err := s.s.Watch(req.Key, req.Prefix, req.StartRevision)
+	if err != nil {
+		return toStatus(s.l, "Watch", err)
+	}
+	defer watcher.Cancel()
+
+	var progressC <-chan time.Time
+	if req.ProgressNotify {
+		ticker := time.NewTicker(watchProgressInterval)
+		defer ticker.Stop()
+		progressC = ticker.C
+	}
+
+	for {
+		select {
+		case e, ok := <-watcher.Events():
+			if !ok {
+				if err := watcher.Err(); err != nil {
+					s.l.Info("watcher closed by store", "key", req.Key, "prefix", req.Prefix, "err", err)
+					return toStatus(s.l, "Watch", err)
+				}
+				return nil
+			}
+
+			if err := stream.Send(newWatchResponse(e)); err != nil {
+				s.l.Debug("watch client gone", "err", err)
+				return err
+			}
+		case <-progressC:
+			s.s.RequestProgress(watcher)
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package store
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// RootRole grants every permission, along with the administration of the
+// cluster and of auth itself.
+const RootRole = "root"
+
+var (
+	UserNotFoundError     = errors.New("user not found")
+	RoleNotFoundError     = errors.New("role not found")
+	TokenNotFoundError    = errors.New("token not found")
+	InvalidTokenError     = errors.New("invalid or expired token")
+	NoRootUserError       = errors.New("auth needs a user with the root role and a token to be enabled")
+	RootRoleFixedError    = errors.New("the root role cannot be changed")
+	LastRootTokenError    = errors.New("auth is enabled and this would remove the last root credential")
+	InvalidAuthNameError  = errors.New("names must not be empty")
+	PermissionDeniedError = errors.New("permission denied")
+)
+
+// Permission grants reading, writing or both on every key starting with
+// Prefix in Namespace, the root key space when empty. An empty prefix covers
+// every key of the namespace.
+type Permission struct {
+	Namespace string
+	Prefix    string
+	Read      bool
+	Write     bool
+}
+
+type Role struct {
+	Name        string
+	Permissions []Permission
+}
+
+type User struct {
+	Name  string
+	Roles []string
+}
+
+// Token authenticates its user. Only the hash of its secret is stored.
+type Token struct {
+	ID   string
+	User string
+	Hash []byte
+	// ExpiresAt is the deadline in unix nanoseconds, zero meaning never.
+	ExpiresAt int64
+}
+
+// HashTokenSecret hashes a token secret. Secrets are random, so a plain
+// hash is enough to keep them from being read back.
+func HashTokenSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+type authState struct {
+	Enabled bool
+	Users   map[string]*User
+	Roles   map[string]*Role
+	Tokens  map[string]*Token
+}
+
+func newAuthState() authState {
+	return authState{
+		Users:  make(map[string]*User),
+		Roles:  make(map[string]*Role),
+		Tokens: make(map[string]*Token),
+	}
+}
+
+// Principal is an authenticated user along with the permissions of its
+// roles.
+type Principal struct {
+	User        string
+	Root        bool
+	Permissions []Permission
+}
+
+// Allows reports whether the principal may read, or write, every key in
+// [start, end) of a namespace. An empty end leaves the range unbounded.
+func (p Principal) Allows(namespace, start, end string, write bool) bool {
+	if p.Root {
+		return true
+	}
+
+	for _, perm := range p.Permissions {
+		if perm.Namespace != namespace || (write && !perm.Write) || (!write && !perm.Read) {
+			continue
+		}
+
+		if !strings.HasPrefix(start, perm.Prefix) {
+			continue
+		}
+
+		permEnd := PrefixEnd(perm.Prefix)
+		if permEnd == "" || (end != "" && end <= permEnd) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Access is a key range of a namespace a request reads or writes, the use of
+// a lease when Lease is set, or the administration of the cluster when Admin
+// is set.
+type Access struct {
+	Start, End string
+	Write      bool
+	Admin      bool
+	// Lease is used by its Owner only, and by root alone when it has none.
+	Lease int64
+	Owner string
+}
+
+func KeyAccess(key string, write bool) Access {
+	return Access{Start: key, End: key + "\x00", Write: write}
+}
+
+func PrefixAccess(prefix string, write bool) Access {
+	return Access{Start: prefix, End: PrefixEnd(prefix), Write: write}
+}
+
+// Permits reports whether the principal may have the access in a namespace.
+// Only root may administer the cluster, and leases are kept to their owner.
+func (p Principal) Permits(namespace string, a Access) bool {
+	switch {
+	case p.Root:
+		return true
+	case a.Lease != 0:
+		return a.Owner != "" && a.Owner == p.User
+	default:
+		return !a.Admin && p.Allows(namespace, a.Start, a.End, a.Write)
+	}
+}
+
+func (a Access) String() string {
+	verb := "read"
+	if a.Write {
+		verb = "write"
+	}
+
+	switch {
+	case a.Admin:
+		return "administer the cluster"
+	case a.Lease != 0:
+		return fmt.Sprintf("use lease %d", a.Lease)
+	case a.End == a.Start+"\x00":
+		return fmt.Sprintf("%s key %q", verb, a.Start)
+	case a.End == "":
+		return fmt.Sprintf("%s keys from %q", verb, a.Start)
+	default:
+		return fmt.Sprintf("%s keys in [%q, %q)", verb, a.Start, a.End)
+	}
+}
+
+func (s *Store) AuthEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.auth.Enabled
+}
+
+// SetAuthEnabled turns auth on or off. Turning it on needs a root user with
+// a token, so the cluster can still be administered afterwards.
+func (s *Store) SetAuthEnabled(enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if enabled && !s.hasRootToken("") {
+		return NoRootUserError
+	}
+	s.auth.Enabled = enabled
+
+	return nil
+}
+
+// hasRootToken reports whether a root user other than exceptUser holds a
+// token. The caller must hold the lock.
+func (s *Store) hasRootToken(exceptUser string) bool {
+	for _, t := range s.auth.Tokens {
+		u, ok := s.auth.Users[t.User]
+		if ok && u.Name != exceptUser && slices.Contains(u.Roles, RootRole) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PutUser creates or replaces a user. Every role must exist, except root.
+func (s *Store) PutUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u.Name == "" {
+		return InvalidAuthNameError
+	}
+	for _, role := range u.Roles {
+		if _, ok := s.auth.Roles[role]; !ok && role != RootRole {
+			return RoleNotFoundError
+		}
+	}
+
+	if s.auth.Enabled && !slices.Contains(u.Roles, RootRole) && !s.hasRootToken(u.Name) {
+		return LastRootTokenError
+	}
+
+	u.Roles = slices.Compact(slices.Sorted(slices.Values(u.Roles)))
+	s.auth.Users[u.Name] = &u
+
+	return nil
+}
+
+// DeleteUser removes a user along with its tokens.
+func (s *Store) DeleteUser(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.auth.Users[name]; !ok {
+		return UserNotFoundError
+	}
+	if s.auth.Enabled && !s.hasRootToken(name) {
+		return LastRootTokenError
+	}
+
+	delete(s.auth.Users, name)
+	maps.DeleteFunc(s.auth.Tokens, func(_ string, t *Token) bool {
+		return t.User == name
+	})
+
+	return nil
+}
+
+func (s *Store) GetUser(name string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.auth.Users[name]
+	if !ok {
+		return User{}, UserNotFoundError
+	}
+
+	return User{Name: u.Name, Roles: slices.Clone(u.Roles)}, nil
+}
+
+// Users returns every user ordered by name.
+func (s *Store) Users() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]User, 0, len(s.auth.Users))
+	for _, u := range s.auth.Users {
+		res = append(res, User{Name: u.Name, Roles: slices.Clone(u.Roles)})
+	}
+	slices.SortFunc(res, func(a, b User) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return res
+}
+
+// PutRole creates or replaces a role.
+func (s *Store) PutRole(r Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Name {
+	case "":
+		return InvalidAuthNameError
+	case RootRole:
+		return RootRoleFixedError
+	}
+
+	s.auth.Roles[r.Name] = &r
+
+	return nil
+}
+
+// DeleteRole removes a role, taking it away from every user.
+func (s *Store) DeleteRole(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name == RootRole {
+		return RootRoleFixedError
+	}
+	if _, ok := s.auth.Roles[name]; !ok {
+		return RoleNotFoundError
+	}
+
+	delete(s.auth.Roles, name)
+	for _, u := range s.auth.Users {
+		u.Roles = slices.DeleteFunc(u.Roles, func(role string) bool { return role == name })
+	}
+
+	return nil
+}
+
+func (s *Store) GetRole(name string) (Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.auth.Roles[name]
+	if !ok {
+		return Role{}, RoleNotFoundError
+	}
+
+	return Role{Name: r.Name, Permissions: slices.Clone(r.Permissions)}, nil
+}
+
+// Roles returns every role ordered by name.
+func (s *Store) Roles() []Role {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Role, 0, len(s.auth.Roles))
+	for _, r := range s.auth.Roles {
+		res = append(res, Role{Name: r.Name, Permissions: slices.Clone(r.Permissions)})
+	}
+	slices.SortFunc(res, func(a, b Role) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return res
+}
+
+// AddToken stores a token for an existing user.
+func (s *Store) AddToken(t Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.auth.Users[t.User]; !ok {
+		return UserNotFoundError
+	}
+
+	s.auth.Tokens[t.ID] = &t
+
+	return nil
+}
+
+func (s *Store) RevokeToken(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.auth.Tokens[id]
+	if !ok {
+		return TokenNotFoundError
+	}
+
+	delete(s.auth.Tokens, id)
+	if s.auth.Enabled && !s.hasRootToken("") {
+		s.auth.Tokens[id] = t
+		return LastRootTokenError
+	}
+
+	return nil
+}
+
+// Tokens returns the tokens of a user, without their hashes, ordered by ID.
+func (s *Store) Tokens(user string) []Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Token, 0)
+	for _, t := range s.auth.Tokens {
+		if t.User == user {
+			res = append(res, Token{ID: t.ID, User: t.User, ExpiresAt: t.ExpiresAt})
+		}
+	}
+	slices.SortFunc(res, func(a, b Token) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return res
+}
+
+// Authenticate checks the token's secret and expiry, now being the current
+// time in unix nanoseconds, and gathers the permissions of its user.
+func (s *Store) Authenticate(id, secret string, now int64) (Principal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.auth.Tokens[id]
+	if !ok || subtle.ConstantTimeCompare(t.Hash, HashTokenSecret(secret)) != 1 {
+		return Principal{}, InvalidTokenError
+	}
+	if t.ExpiresAt != 0 && t.ExpiresAt <= now {
+		return Principal{}, InvalidTokenError
+	}
+
+	u, ok := s.auth.Users[t.User]
+	if !ok {
+		return Principal{}, InvalidTokenError
+	}
+
+	return s.principal(u), nil
+}
+
+// PrincipalOf gathers the permissions a user has now, for checks made as an
+// action applies rather than when it was requested.
+func (s *Store) PrincipalOf(user string) (Principal, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.auth.Users[user]
+	if !ok {
+		return Principal{}, UserNotFoundError
+	}
+
+	return s.principal(u), nil
+}
+
+// principal must be called while holding the lock.
+func (s *Store) principal(u *User) Principal {
+	p := Principal{User: u.Name}
+	for _, role := range u.Roles {
+		if role == RootRole {
+			p.Root = true
+			continue
+		}
+		if r, ok := s.auth.Roles[role]; ok {
+			p.Permissions = append(p.Permissions, r.Permissions...)
+		}
+	}
+
+	return p
+}
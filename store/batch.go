@@ -0,0 +1,106 @@
+package store
+
+import "fmt"
+
+type BatchOp struct {
+	Delete bool
+	// Check only verifies the ModRevision and Existence options, leaving
+	// the key untouched.
+	Check bool
+	// Range reads the entries in [Key, RangeEnd) as left by the operations
+	// before it, deleting them as well along Delete. An empty RangeEnd leaves
+	// the range unbounded.
+	Range    bool
+	RangeEnd string
+	Key      string
+	Value    []byte
+	// Options apply to puts, deletes only honor ModRevision.
+	Options PutOptions
+}
+
+type BatchResult struct {
+	Key      string
+	Revision int64
+	// Found reports, for deletes and checks, whether the key existed.
+	Found bool
+	// Entries holds what a range read, ordered by key.
+	Entries []Entry
+}
+
+// BatchError points at the operation that made a batch fail.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("batch operation %d: %s", e.Index, e.Err)
+}
+
+func (e BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Batch applies every operation under a single revision, or none of them if
+// any precondition fails. Preconditions are checked against the state before
+// the batch.
+func (s *Store) Batch(ops []BatchOp) ([]BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, op := range ops {
+		var err error
+		switch {
+		case op.Range:
+		case op.Check:
+			err = s.checkPut(op.Key, PutOptions{
+				ModRevision: op.Options.ModRevision,
+				Existence:   op.Options.Existence,
+			})
+		case op.Delete:
+			if op.Options.ModRevision != 0 && s.values[op.Key].ModRevision != op.Options.ModRevision {
+				err = PreconditionFailedError
+			}
+		default:
+			err = s.checkPut(op.Key, op.Options)
+		}
+
+		if err != nil {
+			return nil, BatchError{Index: i, Err: err}
+		}
+	}
+
+	s.revision++
+
+	res := make([]BatchResult, 0, len(ops))
+	for _, op := range ops {
+		r := BatchResult{
+			Key:      op.Key,
+			Revision: s.revision,
+		}
+
+		switch {
+		case op.Range:
+			r.Entries = s.rangeEntries(op.Key, op.RangeEnd)
+			if op.Delete {
+				for _, e := range r.Entries {
+					s.delete(e)
+				}
+			}
+		case op.Check:
+			_, r.Found = s.values[op.Key]
+		case op.Delete:
+			e, ok := s.values[op.Key]
+			if ok {
+				s.delete(e)
+			}
+			r.Found = ok
+		default:
+			s.put(op.Key, op.Value, op.Options)
+		}
+
+		res = append(res, r)
+	}
+
+	return res, nil
+}
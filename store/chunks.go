@@ -0,0 +1,132 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+)
+
+// maxPendingUploads bounds the chunked values being assembled at once. A new
+// upload is rejected when the table is full, rather than failing one still in
+// progress.
+const maxPendingUploads = 16
+
+// staleUploadEntries is how many log entries an upload may span. Its chunks
+// and put are proposed back to back, so one started further behind was
+// abandoned without being aborted, and is dropped.
+const staleUploadEntries = 4096
+
+var (
+	ChunksMissingError = errors.New("chunks of the value are missing")
+	UploadsFullError   = errors.New("too many large values are being uploaded at once")
+)
+
+// upload gathers the chunks of a value too large for a single raft entry.
+type upload struct {
+	ID uint64
+	// Entry is the raft log index the first chunk was committed at.
+	Entry  uint64
+	Chunks [][]byte
+	// Broken records a chunk arriving out of order, after a chunk was lost
+	// to a leader change.
+	Broken bool
+	// Rejected records an upload refused for lack of room, so its put fails
+	// with UploadsFullError. It holds no chunks and takes no room, and only
+	// the latest maxPendingUploads are kept.
+	Rejected bool
+}
+
+// AppendChunk adds the chunk at index, committed at the given log entry, to
+// the upload. The first chunk starts the upload over.
+func (s *Store) AppendChunk(id, entry uint64, index int, chunk []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.uploads, func(u *upload) bool { return u.ID == id })
+	if index == 0 {
+		if i >= 0 {
+			s.uploads = slices.Delete(s.uploads, i, i+1)
+		}
+		s.uploads = slices.DeleteFunc(s.uploads, func(u *upload) bool {
+			return u.Entry+staleUploadEntries < entry
+		})
+		if s.pendingUploads() >= maxPendingUploads {
+			s.uploads = append(s.uploads, &upload{ID: id, Entry: entry, Rejected: true})
+			s.trimRejected()
+			return
+		}
+		s.uploads = append(s.uploads, &upload{ID: id, Entry: entry, Chunks: [][]byte{chunk}})
+		return
+	}
+
+	if i < 0 {
+		return
+	}
+
+	u := s.uploads[i]
+	if u.Rejected {
+		return
+	}
+	if index != len(u.Chunks) {
+		u.Broken = true
+		return
+	}
+	u.Chunks = append(u.Chunks, chunk)
+}
+
+// AbortUpload drops an upload whose put will not follow.
+func (s *Store) AbortUpload(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.uploads = slices.DeleteFunc(s.uploads, func(u *upload) bool { return u.ID == id })
+}
+
+// TakeChunks removes the upload and reassembles its value, failing when it
+// does not hold exactly count chunks.
+func (s *Store) TakeChunks(id uint64, count int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.uploads, func(u *upload) bool { return u.ID == id })
+	if i < 0 {
+		return nil, ChunksMissingError
+	}
+
+	u := s.uploads[i]
+	s.uploads = slices.Delete(s.uploads, i, i+1)
+	if u.Rejected {
+		return nil, UploadsFullError
+	}
+	if u.Broken || len(u.Chunks) != count {
+		return nil, ChunksMissingError
+	}
+
+	return bytes.Join(u.Chunks, nil), nil
+}
+
+// pendingUploads counts the uploads taking room in the table. The caller must
+// hold the lock.
+func (s *Store) pendingUploads() int {
+	n := 0
+	for _, u := range s.uploads {
+		if !u.Rejected {
+			n++
+		}
+	}
+
+	return n
+}
+
+// trimRejected keeps the latest maxPendingUploads rejected uploads. The caller
+// must hold the lock.
+func (s *Store) trimRejected() {
+	excess := len(s.uploads) - s.pendingUploads() - maxPendingUploads
+	s.uploads = slices.DeleteFunc(s.uploads, func(u *upload) bool {
+		if excess > 0 && u.Rejected {
+			excess--
+			return true
+		}
+		return false
+	})
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+var (
+	NotIntegerError = errors.New("value is not a 64-bit integer")
+	OverflowError   = errors.New("increment would overflow a 64-bit integer")
+)
+
+// ParseInteger reads a value written by Increment, a base 10 integer.
+func ParseInteger(value []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, NotIntegerError
+	}
+
+	return n, nil
+}
+
+// Increment adds delta to the integer stored in key, starting from zero when
+// the key does not exist. The key keeps its lease and deadline.
+func (s *Store) Increment(key string, delta int64) (Entry, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current int64
+	e, ok := s.values[key]
+	if ok {
+		n, err := ParseInteger(e.Value)
+		if err != nil {
+			return Entry{}, 0, err
+		}
+		current = n
+	}
+
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return Entry{}, 0, OverflowError
+	}
+	current += delta
+
+	s.revision++
+	var prev *Entry
+	if ok {
+		prevEntry := e
+		prev = &prevEntry
+		s.bytes -= e.size()
+	} else {
+		e = Entry{
+			Key:            key,
+			CreateRevision: s.revision,
+		}
+	}
+	e.Value = []byte(strconv.FormatInt(current, 10))
+	e.ModRevision = s.revision
+	e.Version++
+	s.bytes += e.size()
+
+	s.values[key] = e
+	s.watchers.publish(Event{Type: PutEvent, Entry: e, PrevEntry: prev})
+
+	return e, current, nil
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"maps"
+	"slices"
+)
+
+var LeaseNotFoundError = errors.New("lease not found")
+
+type Lease struct {
+	ID  int64
+	TTL int64
+	// ExpiresAt is the replicated deadline in unix nanoseconds, pushed back by
+	// every keep-alive.
+	ExpiresAt int64
+	Keys      map[string]struct{}
+	// Owner is the user that granted the lease, empty when auth was off.
+	Owner string
+}
+
+// KeyList returns the keys attached to the lease in lexical order.
+func (l Lease) KeyList() []string {
+	return slices.Sorted(maps.Keys(l.Keys))
+}
+
+// UseAccess is what reading the lease, keeping it alive, revoking it or
+// attaching keys to it takes.
+func (l Lease) UseAccess() Access {
+	return Access{Lease: l.ID, Owner: l.Owner}
+}
+
+func (s *Store) GrantLease(ttl int64, expiresAt int64, owner string) Lease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextLeaseID++
+	l := &Lease{
+		ID:        s.nextLeaseID,
+		TTL:       ttl,
+		ExpiresAt: expiresAt,
+		Keys:      make(map[string]struct{}),
+		Owner:     owner,
+	}
+	s.leases[l.ID] = l
+
+	return l.copy()
+}
+
+func (s *Store) GetLease(id int64) (Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return Lease{}, LeaseNotFoundError
+	}
+
+	return l.copy(), nil
+}
+
+// Leases returns every live lease ordered by ID.
+func (s *Store) Leases() []Lease {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Lease, 0, len(s.leases))
+	for _, l := range s.leases {
+		res = append(res, l.copy())
+	}
+	slices.SortFunc(res, func(a, b Lease) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return res
+}
+
+func (s *Store) KeepAliveLease(id int64, expiresAt int64) (Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return Lease{}, LeaseNotFoundError
+	}
+	l.ExpiresAt = expiresAt
+
+	return l.copy(), nil
+}
+
+// RevokeLease removes the lease and deletes every key attached to it,
+// returning how many keys were deleted.
+func (s *Store) RevokeLease(id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return 0, LeaseNotFoundError
+	}
+
+	return s.revokeLease(l), nil
+}
+
+// ExpireLease revokes the lease only if its deadline is still expiresAt, so a
+// keep-alive committed in the meantime wins.
+func (s *Store) ExpireLease(id int64, expiresAt int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[id]
+	if !ok || l.ExpiresAt != expiresAt {
+		return 0, LeaseNotFoundError
+	}
+
+	return s.revokeLease(l), nil
+}
+
+// ExpiredLeases lists the leases whose deadline is at or before now.
+func (s *Store) ExpiredLeases(now int64) []Lease {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Lease, 0)
+	for _, l := range s.leases {
+		if l.ExpiresAt <= now {
+			res = append(res, l.copy())
+		}
+	}
+
+	return res
+}
+
+// revokeLease must be called while holding the write lock.
+func (s *Store) revokeLease(l *Lease) int64 {
+	var deleted int64
+	for _, key := range l.KeyList() {
+		if e, ok := s.values[key]; ok {
+			s.revision++
+			s.delete(e)
+			deleted++
+		}
+	}
+	delete(s.leases, l.ID)
+
+	return deleted
+}
+
+func (l *Lease) copy() Lease {
+	res := *l
+	res.Keys = maps.Clone(l.Keys)
+
+	return res
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	keysDesc = prometheus.NewDesc(
+		"kv_store_keys", "Keys held by each key space, the root one having an empty namespace.",
+		[]string{"namespace"}, nil)
+	bytesDesc = prometheus.NewDesc(
+		"kv_store_bytes", "Bytes of the keys and values held by each key space.",
+		[]string{"namespace"}, nil)
+)
+
+// collector reads the usage of every key space when scraped.
+type collector struct {
+	s *Store
+}
+
+// NewCollector exposes the key count and bytes of the root key space and of
+// every namespace.
+func NewCollector(s *Store) prometheus.Collector {
+	return collector{s: s}
+}
+
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- keysDesc
+	ch <- bytesDesc
+}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	collectUsage(ch, "", c.s.Usage())
+	for _, info := range c.s.Namespaces() {
+		collectUsage(ch, info.Name, info.Usage)
+	}
+}
+
+func collectUsage(ch chan<- prometheus.Metric, namespace string, u Usage) {
+	ch <- prometheus.MustNewConstMetric(keysDesc, prometheus.GaugeValue, float64(u.Keys), namespace)
+	ch <- prometheus.MustNewConstMetric(bytesDesc, prometheus.GaugeValue, float64(u.Bytes), namespace)
+}
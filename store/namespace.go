@@ -0,0 +1,113 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"regexp"
+	"slices"
+)
+
+var (
+	NamespaceNotFoundError = errors.New("namespace not found")
+	NamespaceExistsError   = errors.New("namespace already exists")
+	NamespaceDeletedError  = errors.New("namespace was deleted")
+	InvalidNamespaceError  = errors.New("namespace names are 1 to 63 letters, digits, dashes or underscores")
+)
+
+var namespaceName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+// NamespaceInfo describes a namespace's key space.
+type NamespaceInfo struct {
+	Name     string
+	Usage    Usage
+	Quota    Quota
+	Revision int64
+}
+
+// Namespace returns the store holding the keys of a namespace.
+func (s *Store) Namespace(name string) (*Store, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ns, ok := s.namespaces[name]
+	if !ok {
+		return nil, NamespaceNotFoundError
+	}
+
+	return ns, nil
+}
+
+func (s *Store) CreateNamespace(name string) error {
+	if !namespaceName.MatchString(name) {
+		return InvalidNamespaceError
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.namespaces[name]; ok {
+		return NamespaceExistsError
+	}
+	s.namespaces[name] = NewKeyValueStore()
+
+	return nil
+}
+
+// DeleteNamespace drops a namespace along with its keys and leases, cancelling
+// its watchers.
+func (s *Store) DeleteNamespace(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, ok := s.namespaces[name]
+	if !ok {
+		return NamespaceNotFoundError
+	}
+
+	delete(s.namespaces, name)
+	delete(s.quotas, name)
+	delete(s.alarms, name)
+	ns.watchers.cancelAll(NamespaceDeletedError)
+
+	return nil
+}
+
+// Namespaces describes every namespace, ordered by name.
+func (s *Store) Namespaces() []NamespaceInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]NamespaceInfo, 0, len(s.namespaces))
+	for name, ns := range s.namespaces {
+		res = append(res, ns.info(name, s.quotas[name]))
+	}
+	slices.SortFunc(res, func(a, b NamespaceInfo) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return res
+}
+
+func (s *Store) NamespaceInfo(name string) (NamespaceInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ns, ok := s.namespaces[name]
+	if !ok {
+		return NamespaceInfo{}, NamespaceNotFoundError
+	}
+
+	return ns.info(name, s.quotas[name]), nil
+}
+
+func (s *Store) info(name string, q Quota) NamespaceInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return NamespaceInfo{
+		Name:     name,
+		Usage:    Usage{Keys: int64(len(s.values)), Bytes: s.bytes},
+		Quota:    q,
+		Revision: s.revision,
+	}
+}
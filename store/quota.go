@@ -0,0 +1,178 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	NoSpaceError       = errors.New("NOSPACE alarm raised, writes are refused until it is disarmed")
+	AlarmNotFoundError = errors.New("no alarm raised")
+)
+
+// Quota caps the keys and the bytes of keys and values of a key space. Zero
+// leaves a dimension unlimited.
+type Quota struct {
+	MaxKeys  int64
+	MaxBytes int64
+}
+
+// Usage is what a key space holds, or what a write adds to it.
+type Usage struct {
+	Keys  int64
+	Bytes int64
+}
+
+// Alarm is a NOSPACE alarm, raised when a write would exceed the quota of a
+// namespace, or of the whole cluster when Namespace is empty.
+type Alarm struct {
+	Namespace string
+	// Quota is the quota that was hit.
+	Quota Quota
+}
+
+func (e Entry) size() int64 {
+	return int64(len(e.Key) + len(e.Value))
+}
+
+// exceeded reports whether adding growth to usage goes over q. Writes that
+// do not grow a dimension are never refused on it.
+func (q Quota) exceeded(usage, growth Usage) bool {
+	return (q.MaxKeys > 0 && growth.Keys > 0 && usage.Keys+growth.Keys > q.MaxKeys) ||
+		(q.MaxBytes > 0 && growth.Bytes > 0 && usage.Bytes+growth.Bytes > q.MaxBytes)
+}
+
+// Usage returns what this key space holds.
+func (s *Store) Usage() Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Usage{Keys: int64(len(s.values)), Bytes: s.bytes}
+}
+
+// TotalUsage returns what the whole cluster holds, namespaces included.
+func (s *Store) TotalUsage() Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.totalUsage()
+}
+
+// totalUsage sums the usage of the root store and every namespace. The
+// caller must hold the lock.
+func (s *Store) totalUsage() Usage {
+	total := Usage{Keys: int64(len(s.values)), Bytes: s.bytes}
+	for _, ns := range s.namespaces {
+		u := ns.Usage()
+		total.Keys += u.Keys
+		total.Bytes += u.Bytes
+	}
+
+	return total
+}
+
+// Growth returns what putting value under key would add to the key space.
+func (s *Store) Growth(key string, value []byte) Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e := Entry{Key: key, Value: value}
+	prev, ok := s.values[key]
+	if !ok {
+		return Usage{Keys: 1, Bytes: e.size()}
+	}
+
+	return Usage{Bytes: e.size() - prev.size()}
+}
+
+// Quota returns the quota of a namespace, or of the cluster for the empty
+// one.
+func (s *Store) Quota(namespace string) Quota {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.quotas[namespace]
+}
+
+// SetQuota replaces the quota of a namespace, or of the cluster for the empty
+// one. A zero quota removes it.
+func (s *Store) SetQuota(namespace string, q Quota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.namespaces[namespace]; namespace != "" && !ok {
+		return NamespaceNotFoundError
+	}
+
+	if q == (Quota{}) {
+		delete(s.quotas, namespace)
+		return nil
+	}
+	s.quotas[namespace] = q
+
+	return nil
+}
+
+// Alarms lists the raised alarms, the cluster's first.
+func (s *Store) Alarms() []Alarm {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Alarm, 0, len(s.alarms))
+	for namespace := range s.alarms {
+		res = append(res, Alarm{Namespace: namespace, Quota: s.quotas[namespace]})
+	}
+	slices.SortFunc(res, func(a, b Alarm) int {
+		return cmp.Compare(a.Namespace, b.Namespace)
+	})
+
+	return res
+}
+
+// DisarmAlarm lowers the alarm of a namespace, or of the cluster for the
+// empty one, letting writes through again until a quota is hit anew.
+func (s *Store) DisarmAlarm(namespace string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.alarms[namespace]; !ok {
+		return AlarmNotFoundError
+	}
+	delete(s.alarms, namespace)
+
+	return nil
+}
+
+// AdmitWrite decides whether a write growing a namespace's key space, the
+// root one when empty, may be applied. It is refused while an alarm covers
+// the namespace, and raises one when the write would exceed the namespace's
+// or the cluster's quota. Deletes are always admitted, so space can be freed.
+//
+// It runs on apply against replicated state only, so every member decides
+// the same.
+func (s *Store) AdmitWrite(namespace string, growth Usage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.alarms[""]; ok {
+		return NoSpaceError
+	}
+	if _, ok := s.alarms[namespace]; ok {
+		return NoSpaceError
+	}
+
+	if s.quotas[""].exceeded(s.totalUsage(), growth) {
+		s.alarms[""] = true
+		return fmt.Errorf("%w: the cluster quota is exhausted", NoSpaceError)
+	}
+
+	ns, ok := s.namespaces[namespace]
+	if namespace != "" && ok && s.quotas[namespace].exceeded(ns.Usage(), growth) {
+		s.alarms[namespace] = true
+		return fmt.Errorf("%w: the quota of namespace %q is exhausted", NoSpaceError, namespace)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"container/heap"
+	"slices"
+	"strings"
+)
+
+// PrefixEnd returns the range end covering every key starting with prefix,
+// empty when there is no upper bound.
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+
+	return ""
+}
+
+// InRange reports whether key falls in [start, end), an empty end leaving
+// the range unbounded.
+func InRange(key, start, end string) bool {
+	return key >= start && (end == "" || key < end)
+}
+
+// DeleteRange deletes every key in [start, end) under a single revision,
+// returning how many were removed. An empty end leaves the range unbounded.
+func (s *Store) DeleteRange(start, end string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0)
+	for key := range s.values {
+		if InRange(key, start, end) {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return 0
+	}
+	slices.SortFunc(keys, strings.Compare)
+
+	s.revision++
+	for _, key := range keys {
+		s.delete(s.values[key])
+	}
+
+	return int64(len(keys))
+}
+
+// Range returns the entries in [start, end) ordered by key, all read at the
+// returned revision. At most limit entries are returned when it is positive,
+// more reporting whether others were left out.
+func (s *Store) Range(start, end string, limit int) (entries []Entry, revision int64, more bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if limit <= 0 || limit >= len(s.values) {
+		return s.rangeEntries(start, end), s.revision, false
+	}
+
+	res := s.firstEntries(start, end, limit+1)
+	if len(res) > limit {
+		return res[:limit], s.revision, true
+	}
+
+	return res, s.revision, false
+}
+
+// firstEntries returns the first n entries in [start, end) ordered by key,
+// without gathering the others. The caller must hold the lock.
+func (s *Store) firstEntries(start, end string, n int) []Entry {
+	h := make(lastKeyHeap, 0, min(n, len(s.values)))
+	for key, e := range s.values {
+		switch {
+		case !InRange(key, start, end):
+		case len(h) < n:
+			heap.Push(&h, e)
+		case key < h[0].Key:
+			h[0] = e
+			heap.Fix(&h, 0)
+		}
+	}
+	slices.SortFunc(h, func(a, b Entry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return h
+}
+
+// lastKeyHeap keeps the entry with the greatest key on top.
+type lastKeyHeap []Entry
+
+func (h lastKeyHeap) Len() int           { return len(h) }
+func (h lastKeyHeap) Less(i, j int) bool { return h[i].Key > h[j].Key }
+func (h lastKeyHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *lastKeyHeap) Push(x any)        { *h = append(*h, x.(Entry)) }
+
+func (h *lastKeyHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return e
+}
+
+// rangeEntries returns the entries in [start, end) ordered by key. The caller
+// must hold the lock.
+func (s *Store) rangeEntries(start, end string) []Entry {
+	res := make([]Entry, 0)
+	for key, e := range s.values {
+		if InRange(key, start, end) {
+			res = append(res, e)
+		}
+	}
+	slices.SortFunc(res, func(a, b Entry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return res
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+type snapshot struct {
+	Values      map[string]Entry
+	Revision    int64
+	Leases      map[int64]*Lease
+	NextLeaseID int64
+	Uploads     []*upload
+	Auth        authState
+	// Namespaces holds the snapshot of every namespace by name.
+	Namespaces map[string][]byte
+	Quotas     map[string]Quota
+	Alarms     map[string]bool
+}
+
+// Snapshot serializes the replicated state of the store.
+func (s *Store) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	namespaces := make(map[string][]byte, len(s.namespaces))
+	for name, ns := range s.namespaces {
+		data, err := ns.Snapshot()
+		if err != nil {
+			return nil, err
+		}
+		namespaces[name] = data
+	}
+
+	b := new(bytes.Buffer)
+	if err := gob.NewEncoder(b).Encode(snapshot{
+		Values:      s.values,
+		Revision:    s.revision,
+		Leases:      s.leases,
+		NextLeaseID: s.nextLeaseID,
+		Uploads:     s.uploads,
+		Auth:        s.auth,
+		Namespaces:  namespaces,
+		Quotas:      s.quotas,
+		Alarms:      s.alarms,
+	}); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Restore replaces the store contents with a snapshot taken by Snapshot.
+// Watchers are cancelled since the events in between are not available.
+func (s *Store) Restore(data []byte) error {
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
+	}
+
+	if snap.Values == nil {
+		snap.Values = make(map[string]Entry)
+	}
+	if snap.Leases == nil {
+		snap.Leases = make(map[int64]*Lease)
+	}
+	if snap.Auth.Users == nil {
+		snap.Auth.Users = make(map[string]*User)
+	}
+	if snap.Auth.Roles == nil {
+		snap.Auth.Roles = make(map[string]*Role)
+	}
+	if snap.Auth.Tokens == nil {
+		snap.Auth.Tokens = make(map[string]*Token)
+	}
+	if snap.Quotas == nil {
+		snap.Quotas = make(map[string]Quota)
+	}
+	if snap.Alarms == nil {
+		snap.Alarms = make(map[string]bool)
+	}
+	for _, l := range snap.Leases {
+		if l.Keys == nil {
+			l.Keys = make(map[string]struct{})
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.restoreNamespaces(snap.Namespaces); err != nil {
+		return err
+	}
+
+	s.values = snap.Values
+	s.revision = snap.Revision
+	s.leases = snap.Leases
+	s.nextLeaseID = snap.NextLeaseID
+	s.uploads = snap.Uploads
+	s.auth = snap.Auth
+	s.quotas = snap.Quotas
+	s.alarms = snap.Alarms
+	s.bytes = 0
+	s.expiries = newExpiryIndex()
+	for _, e := range s.values {
+		s.bytes += e.size()
+		s.expiries.set(e.Key, e.ExpiresAt)
+	}
+	s.watchers.reset()
+
+	return nil
+}
+
+// restoreNamespaces restores every namespace of a snapshot, in place when it
+// already exists, and drops the others. The caller must hold the write lock.
+func (s *Store) restoreNamespaces(namespaces map[string][]byte) error {
+	for name, data := range namespaces {
+		ns, ok := s.namespaces[name]
+		if !ok {
+			ns = NewKeyValueStore()
+		}
+		if err := ns.Restore(data); err != nil {
+			return err
+		}
+		s.namespaces[name] = ns
+	}
+
+	for name, ns := range s.namespaces {
+		if _, ok := namespaces[name]; !ok {
+			delete(s.namespaces, name)
+			ns.watchers.cancelAll(NamespaceDeletedError)
+		}
+	}
+
+	return nil
+}
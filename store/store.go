@@ -1,38 +1,272 @@
 package store
 
-import "errors"
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
 
-var KeyNotFoundError = errors.New("key not found in store")
+var (
+	KeyNotFoundError        = errors.New("key not found in store")
+	PreconditionFailedError = errors.New("key was modified since the given revision")
+)
+
+type Entry struct {
+	Key            string
+	Value          []byte
+	CreateRevision int64
+	ModRevision    int64
+	Version        int64
+	// ExpiresAt is the replicated deadline, in unix nanoseconds, after which
+	// the leader proposes the key's expiry. Zero means the key never expires.
+	ExpiresAt int64
+	Lease     int64
+	// ContentType is the media type the value was written with, if any.
+	ContentType string
+}
+
+// Existence conditions a put on whether the key is already stored.
+type Existence int
+
+const (
+	AnyExistence Existence = iota
+	MustExist
+	MustNotExist
+)
+
+type PutOptions struct {
+	ExpiresAt   int64
+	Lease       int64
+	ContentType string
+	// ModRevision, when set, only lets the put through if the key is
+	// currently at that revision.
+	ModRevision int64
+	// Existence only lets the put create or only lets it update the key.
+	Existence Existence
+}
+
+type DeleteOptions struct {
+	// ModRevision, when set, only lets the delete through if the key is
+	// currently at that revision.
+	ModRevision int64
+}
 
 type Store struct {
-	values map[string][]byte
+	mu          sync.RWMutex
+	values      map[string]Entry
+	expiries    *expiryIndex
+	revision    int64
+	leases      map[int64]*Lease
+	nextLeaseID int64
+	uploads     []*upload
+	// bytes is the size of the keys and values stored, kept for quotas.
+	bytes int64
+	// quotas and alarms are only held by the root store, by namespace,
+	// the empty one standing for the whole cluster.
+	quotas map[string]Quota
+	alarms map[string]bool
+	auth   authState
+	// namespaces are isolated key spaces, each with its own revisions,
+	// leases and watchers. Only the root store holds any.
+	namespaces map[string]*Store
+	watchers   *watcherHub
 }
 
-func NewKeyValueStore() Store {
-	return Store{
-		values: make(map[string][]byte),
+func NewKeyValueStore() *Store {
+	return &Store{
+		values:     make(map[string]Entry),
+		expiries:   newExpiryIndex(),
+		leases:     make(map[int64]*Lease),
+		quotas:     make(map[string]Quota),
+		alarms:     make(map[string]bool),
+		auth:       newAuthState(),
+		namespaces: make(map[string]*Store),
+		watchers:   newWatcherHub(),
 	}
 }
 
-func (s *Store) Put(key string, value []byte) {
-	s.values[key] = value
+func (s *Store) Put(key string, value []byte, opts PutOptions) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkPut(key, opts); err != nil {
+		return Entry{}, err
+	}
+
+	s.revision++
+
+	return s.put(key, value, opts), nil
 }
 
-func (s Store) Get(key string) ([]byte, error) {
+// checkPut reports whether a put with opts can be applied to key. The caller
+// must hold the lock.
+func (s *Store) checkPut(key string, opts PutOptions) error {
+	if opts.Lease != 0 {
+		if _, ok := s.leases[opts.Lease]; !ok {
+			return LeaseNotFoundError
+		}
+	}
+
+	if opts.ModRevision != 0 && s.values[key].ModRevision != opts.ModRevision {
+		return PreconditionFailedError
+	}
+
+	_, exists := s.values[key]
+	switch {
+	case opts.Existence == MustExist && !exists, opts.Existence == MustNotExist && exists:
+		return PreconditionFailedError
+	}
+
+	return nil
+}
+
+// put stores value under the current revision. The caller must hold the
+// write lock and have bumped the revision.
+func (s *Store) put(key string, value []byte, opts PutOptions) Entry {
+	var prev *Entry
+	e, ok := s.values[key]
+	if ok {
+		prevEntry := e
+		prev = &prevEntry
+		s.bytes -= e.size()
+	} else {
+		e = Entry{
+			Key:            key,
+			CreateRevision: s.revision,
+		}
+	}
+	e.Value = value
+	e.ModRevision = s.revision
+	e.Version++
+	s.bytes += e.size()
+	e.ExpiresAt = opts.ExpiresAt
+	e.ContentType = opts.ContentType
+	s.attachLease(e, opts.Lease)
+	e.Lease = opts.Lease
+
+	s.values[key] = e
+	s.expiries.set(key, e.ExpiresAt)
+	s.watchers.publish(Event{Type: PutEvent, Entry: e, PrevEntry: prev})
+
+	return e
+}
+
+func (s *Store) Get(key string) ([]byte, error) {
+	e, err := s.GetEntry(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Value, nil
+}
+
+func (s *Store) GetEntry(key string) (Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	res, ok := s.values[key]
 	if !ok {
-		return nil, KeyNotFoundError
+		return Entry{}, KeyNotFoundError
 	}
 
 	return res, nil
 }
 
-func (s Store) Delete(key string) error {
-	_, ok := s.values[key]
+// GetMany reads every key at the same revision, which it returns along the
+// entries found and the keys missing.
+func (s *Store) GetMany(keys []string) ([]Entry, []string, int64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found := make([]Entry, 0, len(keys))
+	missing := make([]string, 0)
+	for _, key := range keys {
+		e, ok := s.values[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		found = append(found, e)
+	}
+
+	return found, missing, s.revision
+}
+
+func (s *Store) Delete(key string, opts DeleteOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.values[key]
 	if !ok {
 		return KeyNotFoundError
 	}
 
-	delete(s.values, key)
+	if opts.ModRevision != 0 && e.ModRevision != opts.ModRevision {
+		return PreconditionFailedError
+	}
+
+	s.revision++
+	s.delete(e)
+
 	return nil
 }
+
+// delete removes e under the current revision. The caller must hold the
+// write lock and have bumped the revision.
+func (s *Store) delete(e Entry) {
+	s.attachLease(e, 0)
+	delete(s.values, e.Key)
+	s.expiries.remove(e.Key)
+	s.bytes -= e.size()
+	s.watchers.publish(Event{
+		Type: DeleteEvent,
+		Entry: Entry{
+			Key:         e.Key,
+			ModRevision: s.revision,
+		},
+		PrevEntry: &e,
+	})
+}
+
+// Revision returns the revision of the last applied mutation.
+func (s *Store) Revision() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.revision
+}
+
+// attachLease moves e from its current lease, if any, to lease. The caller
+// must hold the write lock.
+func (s *Store) attachLease(e Entry, lease int64) {
+	if e.Lease == lease {
+		return
+	}
+
+	if l, ok := s.leases[e.Lease]; ok {
+		delete(l.Keys, e.Key)
+	}
+
+	if l, ok := s.leases[lease]; ok {
+		l.Keys[e.Key] = struct{}{}
+	}
+}
+
+// List returns every entry whose key starts with prefix, in key order.
+func (s *Store) List(prefix string) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Entry, 0)
+	for key, e := range s.values {
+		if strings.HasPrefix(key, prefix) {
+			res = append(res, e)
+		}
+	}
+	slices.SortFunc(res, func(a, b Entry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return res
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"container/heap"
+	"iter"
+)
+
+// Expired lists the keys whose deadline is at or before now, in unix
+// nanoseconds. Only the leader should act on it, by proposing their expiry.
+func (s *Store) Expired(now int64) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]Entry, 0)
+	for key := range s.expiries.due(now) {
+		res = append(res, s.values[key])
+	}
+
+	return res
+}
+
+// Expire deletes key as long as it was not modified after modRevision, so a
+// key refreshed while its expiry was being replicated is kept.
+func (s *Store) Expire(key string, modRevision int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.values[key]
+	if !ok || e.ModRevision != modRevision {
+		return KeyNotFoundError
+	}
+
+	s.revision++
+	s.delete(e)
+
+	return nil
+}
+
+type expiry struct {
+	key       string
+	expiresAt int64
+}
+
+// expiryIndex orders the keys that have a deadline by it, so the expired ones
+// are found without going through every value.
+type expiryIndex struct {
+	heap []expiry
+	// pos is the position of each key in heap.
+	pos map[string]int
+}
+
+func newExpiryIndex() *expiryIndex {
+	return &expiryIndex{pos: make(map[string]int)}
+}
+
+// set indexes key under expiresAt, dropping it when zero.
+func (x *expiryIndex) set(key string, expiresAt int64) {
+	i, ok := x.pos[key]
+	switch {
+	case expiresAt == 0:
+		x.remove(key)
+	case ok:
+		x.heap[i].expiresAt = expiresAt
+		heap.Fix(x, i)
+	default:
+		heap.Push(x, expiry{key: key, expiresAt: expiresAt})
+	}
+}
+
+func (x *expiryIndex) remove(key string) {
+	if i, ok := x.pos[key]; ok {
+		heap.Remove(x, i)
+	}
+}
+
+// due yields the keys whose deadline is at or before now, only visiting the
+// part of the heap holding them.
+func (x *expiryIndex) due(now int64) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		stack := []int{0}
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if i >= len(x.heap) || x.heap[i].expiresAt > now {
+				continue
+			}
+			if !yield(x.heap[i].key) {
+				return
+			}
+			stack = append(stack, 2*i+1, 2*i+2)
+		}
+	}
+}
+
+func (x *expiryIndex) Len() int           { return len(x.heap) }
+func (x *expiryIndex) Less(i, j int) bool { return x.heap[i].expiresAt < x.heap[j].expiresAt }
+
+func (x *expiryIndex) Swap(i, j int) {
+	x.heap[i], x.heap[j] = x.heap[j], x.heap[i]
+	x.pos[x.heap[i].key] = i
+	x.pos[x.heap[j].key] = j
+}
+
+func (x *expiryIndex) Push(v any) {
+	e := v.(expiry)
+	x.pos[e.key] = len(x.heap)
+	x.heap = append(x.heap, e)
+}
+
+func (x *expiryIndex) Pop() any {
+	e := x.heap[len(x.heap)-1]
+	x.heap = x.heap[:len(x.heap)-1]
+	delete(x.pos, e.key)
+
+	return e
+}
@@ -0,0 +1,258 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+const (
+	watchHistorySize = 1024
+	watchBufferSize  = 128
+)
+
+var (
+	RevisionCompactedError = errors.New("requested revision is no longer available")
+	WatcherTooSlowError    = errors.New("watcher could not keep up with events")
+)
+
+type EventType int
+
+const (
+	PutEvent EventType = iota
+	DeleteEvent
+	ProgressEvent
+)
+
+func (t EventType) String() string {
+	switch t {
+	case PutEvent:
+		return "PUT"
+	case DeleteEvent:
+		return "DELETE"
+	case ProgressEvent:
+		return "PROGRESS"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Event describes a change applied to the store. Progress events carry no
+// entry and only report, in Entry.ModRevision, that every change up to that
+// revision has already been delivered.
+type Event struct {
+	Type  EventType
+	Entry Entry
+	// PrevEntry is the entry replaced by a put or removed by a delete, nil
+	// when a put created the key.
+	PrevEntry *Entry
+}
+
+type Watcher struct {
+	id     uint64
+	key    string
+	prefix bool
+	hub    *watcherHub
+	events chan Event
+	err    error
+}
+
+// Events is closed once the watcher is cancelled, either by the caller or by
+// the store when the watcher falls behind.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Err reports why the events channel was closed by the store, if it was.
+func (w *Watcher) Err() error {
+	w.hub.mu.Lock()
+	defer w.hub.mu.Unlock()
+
+	return w.err
+}
+
+func (w *Watcher) Cancel() {
+	w.hub.mu.Lock()
+	defer w.hub.mu.Unlock()
+
+	w.hub.remove(w, nil)
+}
+
+func (w *Watcher) matches(key string) bool {
+	if w.prefix {
+		return strings.HasPrefix(key, w.key)
+	}
+
+	return key == w.key
+}
+
+type watcherHub struct {
+	mu       sync.Mutex
+	nextID   uint64
+	watchers map[uint64]*Watcher
+	history  []Event
+}
+
+func newWatcherHub() *watcherHub {
+	return &watcherHub{
+		watchers: make(map[uint64]*Watcher),
+		history:  make([]Event, 0, watchHistorySize),
+	}
+}
+
+// publish must be called while holding the store's write lock so events reach
+// watchers in revision order.
+func (h *watcherHub) publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.history) == watchHistorySize {
+		copy(h.history, h.history[1:])
+		h.history = h.history[:watchHistorySize-1]
+	}
+	h.history = append(h.history, e)
+
+	for _, w := range h.watchers {
+		if !w.matches(e.Entry.Key) {
+			continue
+		}
+
+		select {
+		case w.events <- e:
+		default:
+			h.remove(w, WatcherTooSlowError)
+		}
+	}
+}
+
+func (h *watcherHub) remove(w *Watcher, err error) {
+	if _, ok := h.watchers[w.id]; !ok {
+		return
+	}
+
+	delete(h.watchers, w.id)
+	w.err = err
+	close(w.events)
+}
+
+// Watch subscribes to changes on key, or on every key starting with key when
+// prefix is set. A startRevision greater than zero replays retained events
+// from that revision onwards before any new one.
+func (s *Store) Watch(key string, prefix bool, startRevision int64) (*Watcher, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.watch(key, prefix, startRevision)
+}
+
+// WatchChange watches key for a write or delete after revision after, and
+// reports changed instead when the key's current version is newer already.
+// A key whose current version is not newer cannot have changed since after,
+// so it is watched from the current revision without replaying the history,
+// which may no longer reach back that far. Only a missing key replays it,
+// as it may have been deleted since after.
+func (s *Store) WatchChange(key string, after int64) (w *Watcher, changed bool, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.values[key]
+	switch {
+	case ok && e.ModRevision > after:
+		return nil, true, nil
+	case ok || after >= s.revision:
+		w, err = s.watch(key, false, 0)
+	default:
+		w, err = s.watch(key, false, after+1)
+	}
+
+	return w, false, err
+}
+
+// watch registers a watcher replaying the history from startRevision. The
+// caller must hold the lock.
+func (s *Store) watch(key string, prefix bool, startRevision int64) (*Watcher, error) {
+	h := s.watchers
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	backlog := make([]Event, 0)
+	if startRevision > 0 && startRevision <= s.revision {
+		if len(h.history) == 0 || h.history[0].Entry.ModRevision > startRevision {
+			return nil, RevisionCompactedError
+		}
+
+		for _, e := range h.history {
+			if e.Entry.ModRevision < startRevision {
+				continue
+			}
+			backlog = append(backlog, e)
+		}
+	}
+
+	h.nextID++
+	w := &Watcher{
+		id:     h.nextID,
+		key:    key,
+		prefix: prefix,
+		hub:    h,
+	}
+
+	matching := make([]Event, 0, len(backlog))
+	for _, e := range backlog {
+		if w.matches(e.Entry.Key) {
+			matching = append(matching, e)
+		}
+	}
+
+	w.events = make(chan Event, len(matching)+watchBufferSize)
+	for _, e := range matching {
+		w.events <- e
+	}
+
+	h.watchers[w.id] = w
+
+	return w, nil
+}
+
+// RequestProgress queues a progress event with the current revision behind
+// every event already delivered to w.
+func (s *Store) RequestProgress(w *Watcher) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	h := s.watchers
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.watchers[w.id]; !ok {
+		return
+	}
+
+	select {
+	case w.events <- Event{Type: ProgressEvent, Entry: Entry{ModRevision: s.revision}}:
+	default:
+		h.remove(w, WatcherTooSlowError)
+	}
+}
+
+// reset drops the event history and cancels every watcher, used when the
+// store contents are replaced wholesale.
+func (h *watcherHub) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.history = h.history[:0]
+	for _, w := range h.watchers {
+		h.remove(w, RevisionCompactedError)
+	}
+}
+
+// cancelAll cancels every watcher with err.
+func (h *watcherHub) cancelAll(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, w := range h.watchers {
+		h.remove(w, err)
+	}
+}